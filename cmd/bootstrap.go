@@ -4,7 +4,10 @@ import (
 	"backend-go/config"
 	db "backend-go/database/mongo_db"
 	"backend-go/database/redisx"
+	aApp "backend-go/internal/admin/app"
+	oApp "backend-go/internal/oauth/app"
 	uApp "backend-go/internal/user/app"
+	"backend-go/utils"
 	"fmt"
 	"log"
 	"net/http"
@@ -21,6 +24,22 @@ func loadConfig() {
 	PORT := config.GetEnv("PORT", "8080")
 	config.LoadEnv() // Load environment variables
 
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatal("❌ JWT key init failed: ", err)
+	}
+	if err := utils.InitRBACPolicy(); err != nil {
+		log.Fatal("❌ RBAC policy init failed: ", err)
+	}
+	if err := utils.InitIPBindingPolicy(); err != nil {
+		log.Fatal("❌ Session IP binding init failed: ", err)
+	}
+	if err := utils.InitTrustedProxies(); err != nil {
+		log.Fatal("❌ Trusted proxies init failed: ", err)
+	}
+	if err := utils.InitEncryptionKey(); err != nil {
+		log.Fatal("❌ Encryption key init failed: ", err)
+	}
+
 	mongoDB, err := InitializeMongoDB()
 	if err != nil {
 		log.Fatal("❌ DB init failed: ", err)
@@ -52,6 +71,18 @@ func RegisterWebAppRouter(port string, mongoDB *mongo.Database, redisDB *redisx.
 	}
 	userApp.RegisterRoutes(r.PathPrefix("/api/user").Subrouter())
 
+	oauthApp, err := oApp.NewApp(redisDB, userApp.UserRedisRepo, userApp.TokenValidator, userApp.UserService)
+	if err != nil {
+		log.Fatal("failed to initialize oauth app:", err)
+	}
+	oauthApp.RegisterRoutes(r)
+
+	adminApp, err := aApp.NewApp(userApp.UserMangoRepo, userApp.UserRedisRepo, userApp.APIKeyRepo, userApp.TokenValidator, userApp.UserService)
+	if err != nil {
+		log.Fatal("failed to initialize admin app:", err)
+	}
+	adminApp.RegisterRoutes(r.PathPrefix("/api/admin").Subrouter())
+
 	return r
 }
 
@@ -1,5 +1,63 @@
 package constants
 
-import "backend-go/config"
+import (
+	"backend-go/config"
+	"time"
+)
 
 var DOMAIN = config.GetEnv("DOMAIN", "localhost")
+
+// JWT issuer and audience of the tokens issued by this service
+var JWT_ISSUER = config.GetEnv("JWT_ISSUER", "backend-go")
+var JWT_AUDIENCE = config.GetEnv("JWT_AUDIENCE", "backend-go")
+
+// public base url of this service, the issuer of OpenID Connect ID tokens
+var OIDC_ISSUER = config.GetEnv("OIDC_ISSUER", "http://localhost:8080")
+
+// page of the frontend the password reset link points to, the token is appended as query parameter
+var PASSWORD_RESET_URL = config.GetEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+
+// page of the frontend the email verification link points to
+var EMAIL_VERIFICATION_URL = config.GetEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email")
+
+// page of the frontend the link confirming a new email address points to
+var EMAIL_CHANGE_URL = config.GetEnv("EMAIL_CHANGE_URL", "http://localhost:3000/confirm-email-change")
+
+// page of the frontend the link approving an email change from the current address points to
+var EMAIL_CHANGE_APPROVAL_URL = config.GetEnv("EMAIL_CHANGE_APPROVAL_URL", "http://localhost:3000/approve-email-change")
+
+// page of the frontend the magic login link points to, it has to be opened on the device that asked for it
+var MAGIC_LINK_URL = config.GetEnv("MAGIC_LINK_URL", "http://localhost:3000/magic-login")
+
+// accounts can only log in with a password once their email is verified
+var REQUIRE_EMAIL_VERIFICATION = config.GetEnv("REQUIRE_EMAIL_VERIFICATION", "false") == "true"
+
+// failed logins per account before every further attempt has to wait exponentially longer
+var LOGIN_BACKOFF_THRESHOLD = config.GetEnvInt("LOGIN_BACKOFF_THRESHOLD", 3)
+
+// failed logins per account before the account is locked for LOGIN_LOCKOUT_MINUTES
+var LOGIN_LOCKOUT_THRESHOLD = config.GetEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10)
+var LOGIN_LOCKOUT_DURATION = time.Duration(config.GetEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+
+// rules for new passwords
+var PASSWORD_MIN_LENGTH = config.GetEnvInt("PASSWORD_MIN_LENGTH", 10)
+var PASSWORD_REQUIRE_UPPER = config.GetEnv("PASSWORD_REQUIRE_UPPER", "true") == "true"
+var PASSWORD_REQUIRE_LOWER = config.GetEnv("PASSWORD_REQUIRE_LOWER", "true") == "true"
+var PASSWORD_REQUIRE_DIGIT = config.GetEnv("PASSWORD_REQUIRE_DIGIT", "true") == "true"
+var PASSWORD_REQUIRE_SYMBOL = config.GetEnv("PASSWORD_REQUIRE_SYMBOL", "false") == "true"
+var PASSWORD_DISALLOW_EMAIL = config.GetEnv("PASSWORD_DISALLOW_EMAIL", "true") == "true"
+
+// directory of Have I Been Pwned range files, new passwords found there are refused. The check is off when empty.
+var BREACHED_PASSWORDS_DIR = config.GetEnv("BREACHED_PASSWORDS_DIR", "")
+var BREACHED_PASSWORD_MIN_COUNT = config.GetEnvInt("BREACHED_PASSWORD_MIN_COUNT", 1)
+
+// Argon2id cost of new password hashes, stored hashes with other parameters are upgraded at the next login
+var PASSWORD_HASH_MEMORY_KIB = config.GetEnvInt("PASSWORD_HASH_MEMORY_KIB", 64*1024)
+var PASSWORD_HASH_ITERATIONS = config.GetEnvInt("PASSWORD_HASH_ITERATIONS", 3)
+var PASSWORD_HASH_PARALLELISM = config.GetEnvInt("PASSWORD_HASH_PARALLELISM", 4)
+
+// issuer shown in authenticator apps
+var MFA_ISSUER = config.GetEnv("MFA_ISSUER", "backend-go")
+
+// where /oauth/authorize sends users without a session, it receives the authorization url as return_to
+var OIDC_LOGIN_URL = config.GetEnv("OIDC_LOGIN_URL", "")
@@ -11,6 +11,88 @@ const REFRESH_TOKEN_EXPIRATION_IN_SECONDS = int64(3 * 24 * 60 * 60) // 3 day in
 const ACCESS_TOKEN_EXPIRATION time.Duration = time.Duration(ACCESS_TOKEN_EXPIRATION_IN_SECONDS) * time.Second   // 30 minutes
 const REFRESH_TOKEN_EXPIRATION time.Duration = time.Duration(REFRESH_TOKEN_EXPIRATION_IN_SECONDS) * time.Second // 3 days
 
+const JWT_ID_BYTES = 16 // random bytes of the jti claim
+
+// a retired signing key keeps verifying for the lifetime of the longest lived token it could have signed
+const JWT_KEY_VERIFICATION_GRACE time.Duration = REFRESH_TOKEN_EXPIRATION
+
+// OpenID Connect provider
+const ID_TOKEN_EXPIRATION time.Duration = ACCESS_TOKEN_EXPIRATION
+const AUTHORIZATION_CODE_EXPIRATION time.Duration = 60 * time.Second
+const AUTHORIZATION_CODE string = "oidcAuthCode"
+const SERVICE_TOKEN_EXPIRATION time.Duration = ACCESS_TOKEN_EXPIRATION // access tokens of the client credentials grant
+
+// Sign in with external OpenID Connect providers
+const OIDC_LOGIN_STATE string = "oidcLoginState" // pending logins keyed by the hash of their state
+const OIDC_LOGIN_STATE_EXPIRATION time.Duration = 10 * time.Minute
+const OIDC_LOGIN_STATE_BYTES = 32
+
+// Two factor authentication
+const MFA_TOKEN_EXPIRATION time.Duration = 5 * time.Minute
+const MFA_CHALLENGE_ATTEMPTS string = "mfaChallengeAttempts"   // failed codes per challenge token
+const MFA_CHALLENGE_USED string = "mfaChallengeUsed"           // redeemed challenge tokens by jti
+const MFA_ENROLLMENT_ATTEMPTS string = "mfaEnrollmentAttempts" // codes tried to confirm an enrollment, per user
+const MFA_ENROLLMENT_ATTEMPTS_WINDOW time.Duration = 15 * time.Minute
+const MFA_MAX_ATTEMPTS = 5
+const MFA_RECOVERY_CODES = 10
+
+// Roles and permissions of the default RBAC policy, a policy file can define others
+const ROLE_ADMIN string = "admin"
+const ROLE_USER string = "user"
+
+const PERMISSION_PROFILE_READ string = "profile:read"
+const PERMISSION_PROFILE_WRITE string = "profile:write"
+const PERMISSION_USERS_READ string = "users:read"
+const PERMISSION_USERS_WRITE string = "users:write"
+const PERMISSION_USERS_DELETE string = "users:delete"
+
+// PERMISSION_ACCOUNT_MANAGE lets a login session manage its own account: credentials, API keys, MFA and the session list.
+// Signing out only needs the login session.
+// It is never delegated to OAuth clients nor given to API keys.
+const PERMISSION_ACCOUNT_MANAGE string = "account:manage"
+
+// SCOPE_TOKEN_INTROSPECT registered for an OAuth client lets it call the introspection and revocation endpoints
+const SCOPE_TOKEN_INTROSPECT string = "token:introspect"
+
+// Admin user listing
+const ADMIN_USERS_PAGE_SIZE = 20
+const ADMIN_USERS_MAX_PAGE_SIZE = 100
+
+// Emailed single use tokens, stored by their hash
+const ONE_TIME_TOKEN string = "oneTimeToken"
+const ONE_TIME_TOKEN_BYTES = 32
+const PASSWORD_RESET_PURPOSE string = "passwordReset"
+const PASSWORD_RESET_EXPIRATION time.Duration = 15 * time.Minute
+const EMAIL_VERIFICATION_PURPOSE string = "emailVerification"
+const EMAIL_VERIFICATION_EXPIRATION time.Duration = 24 * time.Hour
+const MAGIC_LINK_PURPOSE string = "magicLink"
+const MAGIC_LINK_EXPIRATION time.Duration = 10 * time.Minute
+const EMAIL_CHANGE_PURPOSE string = "emailChange"
+const EMAIL_CHANGE_EXPIRATION time.Duration = 24 * time.Hour
+const EMAIL_CHANGE_APPROVAL_PURPOSE string = "emailChangeApproval" // sent to the current address of accounts without a password
+const EMAIL_CHANGE_APPROVAL_EXPIRATION time.Duration = 30 * time.Minute
+
+// Personal API keys, the prefix tells them apart from JWTs in the Authorization header
+const API_KEY_PREFIX string = "bgo_pat_"
+const API_KEY_BYTES = 32
+const API_KEY_DISPLAY_LENGTH = 12 // characters of the key kept to recognise it
+const API_KEY_DEFAULT_EXPIRATION time.Duration = 90 * 24 * time.Hour
+const API_KEY_MAX_EXPIRATION time.Duration = 365 * 24 * time.Hour
+const API_KEY_MAX_PER_USER = 20
+const API_KEY_NAME_MAX_LENGTH = 100
+const API_KEY_LAST_USED_INTERVAL time.Duration = time.Minute // last_used_at is written at most this often
+
+// Password hashes
+const PASSWORD_HASH_SALT_BYTES = 16
+const PASSWORD_HASH_KEY_BYTES = 32
+const PASSWORD_MAX_LENGTH = 128 // in characters, bounds the work of hashing
+
+// Failed password logins per account, keyed by the hash of the email so unknown emails are throttled the same way
+const LOGIN_FAILURES string = "loginFailures"
+const LOGIN_BLOCKED_UNTIL string = "loginBlockedUntil"
+const LOGIN_FAILURE_WINDOW time.Duration = time.Hour // failures are forgotten after an hour without a new one
+const LOGIN_BACKOFF_BASE time.Duration = time.Second
+
 // Rate Limiter settings
 const GLOBAL_RATE_LIMITER_RATE = 5                           // in minutes                                     // tokens per minute
 const GLOBAL_RATE_LIMITER_BURST = 5                          // max bucket size
@@ -20,9 +102,17 @@ const INFINITY_TTL time.Duration = 0
 const LOGIN_RATE_LIMITER_RATE = 3  // tokens per minute
 const LOGIN_RATE_LIMITER_BURST = 6 // max bucket size
 
+const EMAIL_VERIFICATION_RATE_LIMITER_RATE = 1  // tokens per minute
+const EMAIL_VERIFICATION_RATE_LIMITER_BURST = 3 // max bucket size
+
 const PROFILE_RATE_LIMITER_RATE = 10  // tokens per minute
 const PROFILE_RATE_LIMITER_BURST = 10 // max bucket size
 
+// Session settings
+const USER_SESSION string = "userSession"            // hash per login session
+const USER_SESSION_INDEX string = "userSessionIndex" // set of session ids per user
+const SESSION_ID_BYTES = 16
+
 // Blacklist settings
 const BLACKLIST_ACCESS_TOKEN string = "blacklistAcessToken"
 
@@ -1,3 +1,4 @@
 package constants
 
 var USER_COLLECTION = "users"
+var API_KEY_COLLECTION = "api_keys"
@@ -13,4 +13,35 @@ var (
 	ErrStoringTokenInDb    = errors.New("error in Database")
 
 	ErrCacheMiss = errors.New("cache miss")
+
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrTokenRevoked     = errors.New("token revoked")
+	ErrClientIPMismatch = errors.New("client ip does not match session")
+	ErrInvalidClient    = errors.New("invalid client credentials")
+	ErrInvalidRedirect  = errors.New("redirect uri is not registered for the client")
+
+	ErrUnknownProvider     = errors.New("unknown identity provider")
+	ErrExternalLoginFailed = errors.New("external login failed")
+	ErrEmailNotVerified    = errors.New("email is not verified")
+
+	ErrMFAAlreadyEnabled  = errors.New("two factor authentication is already enabled")
+	ErrMFANotEnrolled     = errors.New("two factor authentication enrolment not started")
+	ErrInvalidMFACode     = errors.New("invalid two factor code")
+	ErrTooManyMFAAttempts = errors.New("too many two factor attempts")
+
+	ErrAccountDisabled  = errors.New("account is disabled")
+	ErrInvalidRole      = errors.New("role is not defined")
+	ErrCannotModifySelf = errors.New("admins can not change their own account this way")
+	ErrAccountLocked    = errors.New("too many failed logins, try again later")
+	ErrPasswordPolicy   = errors.New("password does not meet the password policy")
+	ErrEmailTaken       = errors.New("email is already in use")
+
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidScope        = errors.New("scope is not granted by the role")
+	ErrTooManyAPIKeys      = errors.New("too many api keys")
+	ErrInvalidAPIKeyName   = errors.New("api key name is empty or too long")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiry is negative")
 )
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"backend-go/constants"
+	handlers "backend-go/internal/admin/handler"
+	"backend-go/internal/admin/services"
+	repository "backend-go/internal/user/repository/mongoDb"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	middleware "backend-go/middlewares"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type App struct {
+	TokenValidator userServices.TokenValidator
+	AdminService   services.AdminService
+	AdminHandler   handlers.AdminHandler
+}
+
+// NewApp initializes everything in one place, the admin module works on the repositories of the user module
+func NewApp(userMongoRepo repository.UserRepository, userRedisRepo redisRepository.UserRedisRepository, apiKeyRepo repository.APIKeyRepository, tokenValidator userServices.TokenValidator, userService userServices.UserService) (*App, error) {
+	service := services.NewAdminService(userMongoRepo, userRedisRepo, apiKeyRepo, userService)
+	handler := handlers.NewAdminHandler(service)
+
+	return &App{
+		TokenValidator: tokenValidator,
+		AdminService:   service,
+		AdminHandler:   handler,
+	}, nil
+}
+
+func (a *App) RegisterRoutes(r *mux.Router) {
+	r.Handle("/users", a.protect(a.AdminHandler.ListUsers, constants.PERMISSION_USERS_READ)).Methods("GET")
+	r.Handle("/users/{userId}", a.protect(a.AdminHandler.GetUser, constants.PERMISSION_USERS_READ)).Methods("GET")
+	r.Handle("/users/{userId}/role", a.protect(a.AdminHandler.ChangeRole, constants.PERMISSION_USERS_WRITE)).Methods("PUT")
+	r.Handle("/users/{userId}/disable", a.protect(a.AdminHandler.DisableUser, constants.PERMISSION_USERS_WRITE)).Methods("POST")
+	r.Handle("/users/{userId}/enable", a.protect(a.AdminHandler.EnableUser, constants.PERMISSION_USERS_WRITE)).Methods("POST")
+	r.Handle("/users/{userId}/logout", a.protect(a.AdminHandler.ForceLogout, constants.PERMISSION_USERS_WRITE)).Methods("POST")
+	r.Handle("/users/{userId}/unlock", a.protect(a.AdminHandler.UnlockLogin, constants.PERMISSION_USERS_WRITE)).Methods("POST")
+	r.Handle("/users/{userId}", a.protect(a.AdminHandler.DeleteUser, constants.PERMISSION_USERS_DELETE)).Methods("DELETE")
+}
+
+// protect requires a signed in user whose role grants the permission, only admins have the users:* permissions by default
+func (a *App) protect(handler http.HandlerFunc, permission string) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequirePermission(permission)(handler), a.TokenValidator)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	domainerrors "backend-go/constants/errors"
+	contextkeys "backend-go/contextKeys"
+	"backend-go/internal/admin/services"
+	userType "backend-go/type"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminHandler interface {
+	ListUsers(w http.ResponseWriter, r *http.Request)
+	GetUser(w http.ResponseWriter, r *http.Request)
+	ChangeRole(w http.ResponseWriter, r *http.Request)
+	DisableUser(w http.ResponseWriter, r *http.Request)
+	EnableUser(w http.ResponseWriter, r *http.Request)
+	ForceLogout(w http.ResponseWriter, r *http.Request)
+	UnlockLogin(w http.ResponseWriter, r *http.Request)
+	DeleteUser(w http.ResponseWriter, r *http.Request)
+}
+
+type AdminHandlerImpl struct {
+	adminService services.AdminService
+}
+
+func NewAdminHandler(s services.AdminService) *AdminHandlerImpl {
+	return &AdminHandlerImpl{
+		adminService: s,
+	}
+}
+
+// ListUsers supports ?page=&limit= and the filters ?email= (substring), ?role= and ?disabled=true|false
+func (h *AdminHandlerImpl) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	filter := userType.UserFilter{
+		Email: query.Get("email"),
+		Role:  query.Get("role"),
+	}
+	if value := query.Get("disabled"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "disabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.Disabled = &disabled
+	}
+
+	result, err := h.adminService.ListUsers(r.Context(), filter, page, limit)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *AdminHandlerImpl) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.adminService.GetUser(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *AdminHandlerImpl) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.ChangeRole(r.Context(), adminID(r), mux.Vars(r)["userId"], req.Role)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *AdminHandlerImpl) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h *AdminHandlerImpl) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *AdminHandlerImpl) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.adminService.ForceLogout(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "User logged out of all sessions",
+		"revoked": revoked,
+	})
+}
+
+func (h *AdminHandlerImpl) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	if err := h.adminService.UnlockLogin(r.Context(), adminID(r), mux.Vars(r)["userId"]); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "User can log in again"})
+}
+
+func (h *AdminHandlerImpl) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.adminService.DeleteUser(r.Context(), adminID(r), mux.Vars(r)["userId"]); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "User deleted"})
+}
+
+// internal functions
+func (h *AdminHandlerImpl) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	user, err := h.adminService.SetDisabled(r.Context(), adminID(r), mux.Vars(r)["userId"], disabled)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// adminID is the user or, for service principals, the client making the request, the routes run behind AuthMiddleware
+func adminID(r *http.Request) string {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok || userContent.Claims == nil {
+		return ""
+	}
+	return userContent.Claims.Subject
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domainerrors.ErrUserNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, domainerrors.ErrInvalidRole):
+		http.Error(w, "role is not defined", http.StatusBadRequest)
+	case errors.Is(err, domainerrors.ErrCannotModifySelf):
+		http.Error(w, "admins can not change their own account here", http.StatusConflict)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	repository "backend-go/internal/user/repository/mongoDb"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AdminService interface {
+	ListUsers(ctx context.Context, filter userType.UserFilter, page int, limit int) (*userType.UserPage, error)
+	GetUser(ctx context.Context, userId string) (*userType.AdminUserView, error)
+	ChangeRole(ctx context.Context, adminId string, userId string, role string) (*userType.AdminUserView, error)
+	SetDisabled(ctx context.Context, adminId string, userId string, disabled bool) (*userType.AdminUserView, error)
+	ForceLogout(ctx context.Context, userId string) (int, error)
+	UnlockLogin(ctx context.Context, adminId string, userId string) error
+	DeleteUser(ctx context.Context, adminId string, userId string) error
+}
+
+type AdminServiceImpl struct {
+	repo        repository.UserRepository
+	redisRepo   redisRepository.UserRedisRepository
+	apiKeyRepo  repository.APIKeyRepository
+	userService userServices.UserService
+}
+
+func NewAdminService(r repository.UserRepository, redisRepo redisRepository.UserRedisRepository, apiKeyRepo repository.APIKeyRepository, userService userServices.UserService) *AdminServiceImpl {
+	return &AdminServiceImpl{
+		repo:        r,
+		redisRepo:   redisRepo,
+		apiKeyRepo:  apiKeyRepo,
+		userService: userService,
+	}
+}
+
+func (s *AdminServiceImpl) ListUsers(ctx context.Context, filter userType.UserFilter, page int, limit int) (*userType.UserPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = constants.ADMIN_USERS_PAGE_SIZE
+	}
+	if limit > constants.ADMIN_USERS_MAX_PAGE_SIZE {
+		limit = constants.ADMIN_USERS_MAX_PAGE_SIZE
+	}
+
+	users, total, err := s.repo.List(ctx, filter, page, limit)
+	if err != nil {
+		log.Printf("adminService.ListUsers: %v", err)
+		return nil, err
+	}
+
+	views := make([]userType.AdminUserView, 0, len(users))
+	for _, user := range users {
+		views = append(views, userType.NewAdminUserView(user))
+	}
+
+	return &userType.UserPage{Users: views, Page: page, Limit: limit, Total: total}, nil
+}
+
+func (s *AdminServiceImpl) GetUser(ctx context.Context, userId string) (*userType.AdminUserView, error) {
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	view := userType.NewAdminUserView(*user)
+	return &view, nil
+}
+
+// ChangeRole ends the user's sessions, tokens issued with the old role must not outlive the change
+func (s *AdminServiceImpl) ChangeRole(ctx context.Context, adminId string, userId string, role string) (*userType.AdminUserView, error) {
+	if adminId == userId {
+		return nil, domainerrors.ErrCannotModifySelf
+	}
+	if !utils.IsKnownRole(role) {
+		return nil, domainerrors.ErrInvalidRole
+	}
+
+	user, err := s.updateUser(ctx, userId, bson.M{"role": role})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ForceLogout(ctx, userId); err != nil {
+		return nil, err
+	}
+	utils.LogSecurityEvent("admin_role_changed", "admin %s set role of user %s to %s", adminId, userId, role)
+
+	view := userType.NewAdminUserView(*user)
+	return &view, nil
+}
+
+// SetDisabled disables or enables an account, disabling also signs the user out everywhere
+func (s *AdminServiceImpl) SetDisabled(ctx context.Context, adminId string, userId string, disabled bool) (*userType.AdminUserView, error) {
+	if adminId == userId {
+		return nil, domainerrors.ErrCannotModifySelf
+	}
+
+	user, err := s.updateUser(ctx, userId, bson.M{"disabled": disabled})
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		if _, err := s.ForceLogout(ctx, userId); err != nil {
+			return nil, err
+		}
+	}
+	utils.LogSecurityEvent("admin_account_disabled", "admin %s set disabled=%t for user %s", adminId, disabled, userId)
+
+	view := userType.NewAdminUserView(*user)
+	return &view, nil
+}
+
+// ForceLogout ends every session of the user, which invalidates all of their access and refresh tokens
+func (s *AdminServiceImpl) ForceLogout(ctx context.Context, userId string) (int, error) {
+	if _, err := s.findUser(ctx, userId); err != nil {
+		return 0, err
+	}
+
+	return s.userService.RevokeOtherSessions(ctx, userId, "")
+}
+
+// UnlockLogin clears the failed login count of the user, which lifts any back-off or lock on password logins
+func (s *AdminServiceImpl) UnlockLogin(ctx context.Context, adminId string, userId string) error {
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.redisRepo.ResetLoginFailures(ctx, user.Email); err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("admin_login_unlocked", "admin %s unlocked logins of user %s", adminId, userId)
+
+	return nil
+}
+
+func (s *AdminServiceImpl) DeleteUser(ctx context.Context, adminId string, userId string) error {
+	if adminId == userId {
+		return domainerrors.ErrCannotModifySelf
+	}
+	if _, err := s.ForceLogout(ctx, userId); err != nil {
+		return err
+	}
+
+	if _, err := s.apiKeyRepo.DeleteAllForUser(ctx, userId); err != nil {
+		log.Printf("adminService.DeleteUser: %v", err)
+		return err
+	}
+	if err := s.repo.DeleteByID(ctx, userId); err != nil {
+		log.Printf("adminService.DeleteUser: %v", err)
+		return err
+	}
+	if _, err := s.redisRepo.DeleteUser(ctx, userId); err != nil {
+		log.Printf("Failed to delete user profile in Redis: %v", err)
+	}
+	utils.LogSecurityEvent("admin_account_deleted", "admin %s deleted user %s", adminId, userId)
+
+	return nil
+}
+
+// internal functions
+func (s *AdminServiceImpl) findUser(ctx context.Context, userId string) (*model.User, error) {
+	if !primitive.IsValidObjectID(userId) {
+		return nil, domainerrors.ErrUserNotFound
+	}
+
+	user, err := s.repo.FindByID(ctx, userId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domainerrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// updateUser writes the changes and clears the cached profile (cache aside strategy- clear on write)
+func (s *AdminServiceImpl) updateUser(ctx context.Context, userId string, updates bson.M) (*model.User, error) {
+	if !primitive.IsValidObjectID(userId) {
+		return nil, domainerrors.ErrUserNotFound
+	}
+
+	user, err := s.repo.UpdateByID(ctx, userId, updates)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domainerrors.ErrUserNotFound
+		}
+		log.Printf("adminService.updateUser: %v", err)
+		return nil, err
+	}
+	if _, err := s.redisRepo.DeleteUser(ctx, userId); err != nil {
+		log.Printf("Failed to delete user profile in Redis: %v", err)
+	}
+
+	return user, nil
+}
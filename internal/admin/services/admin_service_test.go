@@ -0,0 +1,184 @@
+package services_test
+
+import (
+	domainerrors "backend-go/constants/errors"
+	"backend-go/internal/admin/services"
+	repository "backend-go/internal/user/repository/mongoDb"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	model "backend-go/models"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	adminID = "650000000000000000000001"
+	userID  = "650000000000000000000002"
+)
+
+// fakeUsers keeps the user documents in memory, other methods are not used by the admin service
+type fakeUsers struct {
+	repository.UserRepository
+	users   map[string]model.User
+	updates int
+}
+
+func (f *fakeUsers) FindByID(ctx context.Context, id string) (*model.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &user, nil
+}
+
+func (f *fakeUsers) UpdateByID(ctx context.Context, id string, updatedData bson.M) (*model.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	f.updates++
+	if role, ok := updatedData["role"].(string); ok {
+		user.Role = role
+	}
+	if disabled, ok := updatedData["disabled"].(bool); ok {
+		user.Disabled = disabled
+	}
+	f.users[id] = user
+	return &user, nil
+}
+
+func (f *fakeUsers) DeleteByID(ctx context.Context, id string) error {
+	delete(f.users, id)
+	return nil
+}
+
+type fakeUserRedis struct {
+	redisRepository.UserRedisRepository
+	evicted []string
+}
+
+func (f *fakeUserRedis) DeleteUser(ctx context.Context, userID string) (interface{}, error) {
+	f.evicted = append(f.evicted, userID)
+	return nil, nil
+}
+
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+	deletedFor []string
+}
+
+func (f *fakeAPIKeys) DeleteAllForUser(ctx context.Context, userID string) (int64, error) {
+	f.deletedFor = append(f.deletedFor, userID)
+	return 1, nil
+}
+
+// fakeSessions records whose sessions were revoked and which session was kept
+type fakeSessions struct {
+	userServices.UserService
+	revoked map[string]string
+}
+
+func (f *fakeSessions) RevokeOtherSessions(ctx context.Context, userId string, currentSessionId string) (int, error) {
+	f.revoked[userId] = currentSessionId
+	return 2, nil
+}
+
+type adminFixture struct {
+	service  *services.AdminServiceImpl
+	users    *fakeUsers
+	redis    *fakeUserRedis
+	apiKeys  *fakeAPIKeys
+	sessions *fakeSessions
+}
+
+func newAdminFixture() *adminFixture {
+	f := &adminFixture{
+		users: &fakeUsers{users: map[string]model.User{
+			adminID: {ID: adminID, Email: "admin@gmail.com", Role: "admin"},
+			userID:  {ID: userID, Email: "test@gmail.com", Role: "user"},
+		}},
+		redis:    &fakeUserRedis{},
+		apiKeys:  &fakeAPIKeys{},
+		sessions: &fakeSessions{revoked: map[string]string{}},
+	}
+	f.service = services.NewAdminService(f.users, f.redis, f.apiKeys, f.sessions)
+	return f
+}
+
+func TestAdminService_RefusesSelfModification(t *testing.T) {
+	f := newAdminFixture()
+	ctx := context.Background()
+
+	_, err := f.service.ChangeRole(ctx, adminID, adminID, "user")
+	assert.ErrorIs(t, err, domainerrors.ErrCannotModifySelf)
+	_, err = f.service.SetDisabled(ctx, adminID, adminID, true)
+	assert.ErrorIs(t, err, domainerrors.ErrCannotModifySelf)
+	err = f.service.DeleteUser(ctx, adminID, adminID)
+	assert.ErrorIs(t, err, domainerrors.ErrCannotModifySelf)
+
+	assert.Zero(t, f.users.updates)
+	assert.Contains(t, f.users.users, adminID)
+	assert.Empty(t, f.sessions.revoked, "the admin keeps their sessions")
+	assert.Empty(t, f.apiKeys.deletedFor)
+}
+
+func TestAdminService_ForceLogout(t *testing.T) {
+	f := newAdminFixture()
+	ctx := context.Background()
+
+	revoked, err := f.service.ForceLogout(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+	require.Contains(t, f.sessions.revoked, userID)
+	assert.Empty(t, f.sessions.revoked[userID], "no session is kept")
+
+	_, err = f.service.ForceLogout(ctx, "650000000000000000000099")
+	assert.ErrorIs(t, err, domainerrors.ErrUserNotFound)
+	_, err = f.service.ForceLogout(ctx, "not-an-object-id")
+	assert.ErrorIs(t, err, domainerrors.ErrUserNotFound)
+	assert.Len(t, f.sessions.revoked, 1)
+}
+
+func TestAdminService_ChangeRole(t *testing.T) {
+	f := newAdminFixture()
+	ctx := context.Background()
+
+	_, err := f.service.ChangeRole(ctx, adminID, userID, "superuser")
+	assert.ErrorIs(t, err, domainerrors.ErrInvalidRole)
+	assert.Empty(t, f.sessions.revoked)
+
+	view, err := f.service.ChangeRole(ctx, adminID, userID, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", view.Role)
+	assert.Contains(t, f.redis.evicted, userID, "the cached profile still has the old role")
+	assert.Contains(t, f.sessions.revoked, userID, "tokens issued with the old role end")
+}
+
+func TestAdminService_SetDisabled(t *testing.T) {
+	f := newAdminFixture()
+	ctx := context.Background()
+
+	_, err := f.service.SetDisabled(ctx, adminID, userID, false)
+	require.NoError(t, err)
+	assert.Empty(t, f.sessions.revoked, "enabling an account keeps its sessions")
+
+	view, err := f.service.SetDisabled(ctx, adminID, userID, true)
+	require.NoError(t, err)
+	assert.True(t, view.Disabled)
+	assert.Contains(t, f.sessions.revoked, userID)
+}
+
+func TestAdminService_DeleteUser(t *testing.T) {
+	f := newAdminFixture()
+
+	require.NoError(t, f.service.DeleteUser(context.Background(), adminID, userID))
+	assert.NotContains(t, f.users.users, userID)
+	assert.Equal(t, []string{userID}, f.apiKeys.deletedFor)
+	assert.Contains(t, f.sessions.revoked, userID)
+	assert.Contains(t, f.redis.evicted, userID)
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"backend-go/config"
+	"backend-go/database/redisx"
+	handlers "backend-go/internal/oauth/handler"
+	repository "backend-go/internal/oauth/repository/file"
+	oauthRedisRepository "backend-go/internal/oauth/repository/redis"
+	"backend-go/internal/oauth/services"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	"context"
+
+	"github.com/gorilla/mux"
+)
+
+type App struct {
+	ClientRepo     repository.ClientRepository
+	OAuthRedisRepo oauthRedisRepository.OAuthRedisRepository
+	TokenValidator userServices.TokenValidator
+	OAuthService   services.OAuthService
+	OAuthHandler   handlers.OAuthHandler
+}
+
+// NewApp initializes everything in one place
+func NewApp(redisDB *redisx.Client, userRedisRepo redisRepository.UserRedisRepository, tokenValidator userServices.TokenValidator, userService userServices.UserService) (*App, error) {
+	clientRepo, err := repository.NewClientRepository(config.GetEnv("OAUTH_CLIENTS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	oauthRedisRepo := oauthRedisRepository.NewOAuthCache(redisDB)
+
+	service := services.NewOAuthService(clientRepo, oauthRedisRepo, userRedisRepo, tokenValidator, userService)
+	if err := service.CheckIDTokenSigning(context.Background()); err != nil {
+		return nil, err
+	}
+	handler := handlers.NewOAuthHandler(service)
+
+	return &App{
+		ClientRepo:     clientRepo,
+		OAuthRedisRepo: oauthRedisRepo,
+		TokenValidator: tokenValidator,
+		OAuthService:   service,
+		OAuthHandler:   handler,
+	}, nil
+}
+
+// RegisterRoutes registers the well-known and oauth endpoints, they live at the root of the host
+func (a *App) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/.well-known/jwks.json", a.OAuthHandler.JWKS).Methods("GET")
+	r.HandleFunc("/.well-known/openid-configuration", a.OAuthHandler.Discovery).Methods("GET")
+
+	oauth := r.PathPrefix("/oauth").Subrouter()
+	oauth.HandleFunc("/introspect", a.OAuthHandler.Introspect).Methods("POST")
+	oauth.HandleFunc("/revoke", a.OAuthHandler.Revoke).Methods("POST")
+	oauth.HandleFunc("/authorize", a.OAuthHandler.Authorize).Methods("GET")
+	oauth.HandleFunc("/token", a.OAuthHandler.Token).Methods("POST")
+	oauth.HandleFunc("/userinfo", a.OAuthHandler.UserInfo).Methods("GET", "POST")
+}
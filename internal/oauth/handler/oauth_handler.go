@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	domainerrors "backend-go/constants/errors"
+	"backend-go/internal/oauth/services"
+	model "backend-go/models"
+	"backend-go/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type OAuthHandler interface {
+	JWKS(w http.ResponseWriter, r *http.Request)
+	Introspect(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+	Discovery(w http.ResponseWriter, r *http.Request)
+	Authorize(w http.ResponseWriter, r *http.Request)
+	Token(w http.ResponseWriter, r *http.Request)
+	UserInfo(w http.ResponseWriter, r *http.Request)
+}
+
+type OAuthHandlerImpl struct {
+	oauthService services.OAuthService
+}
+
+func NewOAuthHandler(s services.OAuthService) *OAuthHandlerImpl {
+	return &OAuthHandlerImpl{
+		oauthService: s,
+	}
+}
+
+// JWKS publishes the public keys other services verify our tokens with
+func (h *OAuthHandlerImpl) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(utils.PublicJWKS())
+}
+
+// Introspect implements RFC 7662. The optional client_ip parameter enables the session ip binding check.
+func (h *OAuthHandlerImpl) Introspect(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authenticateServiceClient(w, r); !ok {
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	resp, err := h.oauthService.Introspect(r.Context(), token, r.PostFormValue("token_type_hint"), r.PostFormValue("client_ip"))
+	if err != nil {
+		log.Printf("oauthHandler.Introspect: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Revoke implements RFC 7009
+func (h *OAuthHandlerImpl) Revoke(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authenticateServiceClient(w, r); !ok {
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	if err := h.oauthService.Revoke(r.Context(), token, r.PostFormValue("token_type_hint")); err != nil {
+		log.Printf("oauthHandler.Revoke: %v", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// internal functions
+
+// authenticateClient accepts client_secret_basic and client_secret_post credentials
+func (h *OAuthHandlerImpl) authenticateClient(w http.ResponseWriter, r *http.Request) (*model.OAuthClient, bool) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	client, err := h.oauthService.AuthenticateClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, domainerrors.ErrInvalidClient) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		} else {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return nil, false
+	}
+
+	return client, true
+}
+
+// authenticateServiceClient only lets services through, relying parties must not learn about the tokens they hold
+func (h *OAuthHandlerImpl) authenticateServiceClient(w http.ResponseWriter, r *http.Request) (*model.OAuthClient, bool) {
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !services.CanInspectTokens(client) {
+		log.Printf("oauthHandler: client %s is not allowed to introspect or revoke tokens", client.ClientID)
+		writeOAuthError(w, http.StatusForbidden, "unauthorized_client", "the client may not introspect or revoke tokens")
+		return nil, false
+	}
+
+	return client, true
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,268 @@
+package handlers_test
+
+import (
+	"backend-go/constants"
+	handlers "backend-go/internal/oauth/handler"
+	"backend-go/internal/oauth/services"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRedis keeps sessions and the denylist in memory, other methods are not used by introspection and revocation
+type fakeUserRedis struct {
+	redisRepository.UserRedisRepository
+	sessions    map[string]rdsModel.Session
+	blacklisted map[string]bool
+}
+
+func (f *fakeUserRedis) GetSession(ctx context.Context, sessionID string) (*rdsModel.Session, error) {
+	session, ok := f.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (f *fakeUserRedis) DeleteSession(ctx context.Context, userID string, sessionID string) (interface{}, error) {
+	delete(f.sessions, sessionID)
+	return nil, nil
+}
+
+func (f *fakeUserRedis) SetBlacklistOfAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) (interface{}, error) {
+	f.blacklisted[tokenID] = true
+	return nil, nil
+}
+
+func (f *fakeUserRedis) IsBlacklistedAccessToken(ctx context.Context, tokenID string) (bool, error) {
+	return f.blacklisted[tokenID], nil
+}
+
+// fakeUsers serves the profile userinfo reads
+type fakeUsers struct {
+	userServices.UserService
+}
+
+func (f fakeUsers) Profile(ctx context.Context, userId string, clientIp string) (*model.User, error) {
+	return &model.User{ID: userId, Email: "test@gmail.com", Role: "user"}, nil
+}
+
+type fakeClients map[string]model.OAuthClient
+
+func (f fakeClients) FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	client, ok := f[clientID]
+	if !ok {
+		return nil, nil
+	}
+	return &client, nil
+}
+
+func (f fakeClients) FindAll(ctx context.Context) ([]model.OAuthClient, error) {
+	clients := make([]model.OAuthClient, 0, len(f))
+	for _, client := range f {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+type oauthFixture struct {
+	handler      handlers.OAuthHandler
+	redis        *fakeUserRedis
+	accessToken  string
+	refreshToken string
+}
+
+func newOAuthFixture(t *testing.T) *oauthFixture {
+	redis := &fakeUserRedis{sessions: map[string]rdsModel.Session{}, blacklisted: map[string]bool{}}
+	clients := fakeClients{
+		"resource-server": {ClientID: "resource-server", SecretHash: utils.HashToken("secret"), Scopes: []string{constants.SCOPE_TOKEN_INTROSPECT}},
+		"web-app":         {ClientID: "web-app", SecretHash: utils.HashToken("secret"), RedirectURIs: []string{"https://app.example/callback"}},
+	}
+	validator := userServices.NewTokenValidator(redis, nil, nil)
+	service := services.NewOAuthService(clients, nil, redis, validator, fakeUsers{})
+
+	accessToken, err := utils.GenerateAccessToken("user123", "test@gmail.com", "user", "session123", "profile:read")
+	require.NoError(t, err)
+	refreshToken, err := utils.GenerateRefreshToken("user123", "test@gmail.com", "user", "session123")
+	require.NoError(t, err)
+	redis.sessions["session123"] = rdsModel.Session{
+		SessionID:    "session123",
+		UserID:       "user123",
+		RefreshToken: refreshToken,
+		IPAddress:    "203.0.113.7",
+	}
+
+	return &oauthFixture{
+		handler:      handlers.NewOAuthHandler(service),
+		redis:        redis,
+		accessToken:  accessToken,
+		refreshToken: refreshToken,
+	}
+}
+
+func (f *oauthFixture) post(handler http.HandlerFunc, form url.Values, authenticated bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authenticated {
+		req.SetBasicAuth("resource-server", "secret")
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func (f *oauthFixture) introspect(t *testing.T, form url.Values) userType.IntrospectionResponse {
+	rec := f.post(f.handler.Introspect, form, true)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp userType.IntrospectionResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestIntrospect_ActiveTokens(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	resp := f.introspect(t, url.Values{"token": {f.accessToken}})
+	assert.True(t, resp.Active)
+	assert.Equal(t, services.AccessTokenHint, resp.TokenType)
+	assert.Equal(t, "user123", resp.Subject)
+	assert.Equal(t, "session123", resp.SessionID)
+	assert.Equal(t, "profile:read", resp.Scope)
+
+	resp = f.introspect(t, url.Values{"token": {f.refreshToken}, "token_type_hint": {services.RefreshTokenHint}})
+	assert.True(t, resp.Active)
+	assert.Equal(t, services.RefreshTokenHint, resp.TokenType)
+
+	serviceToken, err := utils.GenerateServiceToken("billing", "users:read")
+	require.NoError(t, err)
+	resp = f.introspect(t, url.Values{"token": {serviceToken}})
+	assert.True(t, resp.Active)
+	assert.Equal(t, "billing", resp.ClientID)
+	assert.Equal(t, "billing", resp.Subject)
+}
+
+func TestIntrospect_InactiveTokens(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	assert.False(t, f.introspect(t, url.Values{"token": {"not-a-token"}}).Active)
+	assert.False(t, f.introspect(t, url.Values{"token": {f.accessToken}, "client_ip": {"198.51.100.1"}}).Active,
+		"the session is bound to another ip address")
+
+	mfaToken, err := utils.GenerateMFAToken("user123", "test@gmail.com", "")
+	require.NoError(t, err)
+	assert.False(t, f.introspect(t, url.Values{"token": {mfaToken}}).Active, "MFA challenges are no access tokens")
+}
+
+func TestIntrospect_RequiresClientAuthentication(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	rec := f.post(f.handler.Introspect, url.Values{"token": {f.accessToken}}, false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	rec = f.post(f.handler.Revoke, url.Values{"token": {f.accessToken}}, false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestIntrospect_RequiresServiceClient(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	for _, handler := range []http.HandlerFunc{f.handler.Introspect, f.handler.Revoke} {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(url.Values{"token": {f.accessToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("web-app", "secret")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, "relying parties must not learn about the tokens they hold")
+		assert.Contains(t, rec.Body.String(), "unauthorized_client")
+	}
+	assert.False(t, f.redis.blacklisted[mustClaims(t, f.accessToken).ID], "the refused revocation did nothing")
+}
+
+func TestIntrospect_RotatedRefreshTokenKeepsSession(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	rotated, err := utils.GenerateRefreshToken("user123", "test@gmail.com", "user", "session123")
+	require.NoError(t, err)
+	session := f.redis.sessions["session123"]
+	session.RefreshToken = rotated
+	f.redis.sessions["session123"] = session
+
+	resp := f.introspect(t, url.Values{"token": {f.refreshToken}, "token_type_hint": {services.RefreshTokenHint}})
+	assert.False(t, resp.Active, "the presented refresh token was already rotated")
+	assert.Contains(t, f.redis.sessions, "session123", "introspection is read-only")
+}
+
+func TestRevoke_AccessToken(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	rec := f.post(f.handler.Revoke, url.Values{"token": {f.accessToken}}, true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.False(t, f.introspect(t, url.Values{"token": {f.accessToken}}).Active)
+	assert.Contains(t, f.redis.sessions, "session123", "revoking an access token keeps the session")
+}
+
+func TestRevoke_RefreshTokenEndsSession(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	rec := f.post(f.handler.Revoke, url.Values{"token": {f.refreshToken}, "token_type_hint": {services.RefreshTokenHint}}, true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NotContains(t, f.redis.sessions, "session123")
+	assert.False(t, f.introspect(t, url.Values{"token": {f.accessToken}}).Active, "access tokens of the session end with it")
+}
+
+func TestRevoke_UnknownToken(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	rec := f.post(f.handler.Revoke, url.Values{"token": {"not-a-token"}}, true)
+	assert.Equal(t, http.StatusOK, rec.Code, "RFC 7009 section 2.2")
+
+	rec = f.post(f.handler.Revoke, url.Values{}, true)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUserInfo_IgnoresSessionIPBinding(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	// relying parties call userinfo from their backend, not from the address the user signed in from
+	req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	req.RemoteAddr = "198.51.100.1:443"
+	req.Header.Set("Authorization", "Bearer "+f.accessToken)
+	rec := httptest.NewRecorder()
+	f.handler.UserInfo(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&info))
+	assert.Equal(t, "user123", info["sub"])
+
+	req = httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	rec = httptest.NewRecorder()
+	f.handler.UserInfo(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func mustClaims(t *testing.T, token string) *utils.Claims {
+	claims, err := utils.VerifyAndParseJWTToken(token, utils.AccessTokenType)
+	require.NoError(t, err)
+	return claims
+}
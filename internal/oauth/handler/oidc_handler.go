@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/internal/oauth/services"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// Discovery serves the OpenID Provider metadata
+func (h *OAuthHandlerImpl) Discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.oauthService.Discovery())
+}
+
+// Authorize is the authorization endpoint of the code flow. The user must already be signed in through /api/user/login,
+// the browser presents the access token cookie set there.
+func (h *OAuthHandlerImpl) Authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := userType.AuthorizationRequest{
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		Prompt:              query.Get("prompt"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	_, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), req)
+	if err != nil {
+		var oauthErr *services.OAuthError
+		switch {
+		case errors.As(err, &oauthErr):
+			redirectWithError(w, r, req, oauthErr.Code, oauthErr.Description)
+		case errors.Is(err, domainerrors.ErrInvalidClient):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "unknown client_id")
+		case errors.Is(err, domainerrors.ErrInvalidRedirect):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "redirect_uri is not registered")
+		default:
+			log.Printf("oauthHandler.Authorize: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	clientIp := utils.GetClientIP(r)
+	claims, session, err := h.oauthService.AuthenticateUser(r.Context(), accessTokenFromRequest(r), clientIp)
+	if err != nil {
+		if constants.OIDC_LOGIN_URL != "" && req.Prompt != "none" {
+			loginURL := constants.OIDC_LOGIN_URL + "?return_to=" + url.QueryEscape(r.URL.RequestURI())
+			http.Redirect(w, r, loginURL, http.StatusFound)
+			return
+		}
+		redirectWithError(w, r, req, "login_required", "the user is not signed in")
+		return
+	}
+
+	code, err := h.oauthService.CreateAuthorizationCode(r.Context(), req, claims, session)
+	if err != nil {
+		log.Printf("oauthHandler.Authorize: %v", err)
+		redirectWithError(w, r, req, "server_error", "")
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	http.Redirect(w, r, appendQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+// Token is the token endpoint, it redeems authorization codes and issues client credentials tokens
+func (h *OAuthHandlerImpl) Token(w http.ResponseWriter, r *http.Request) {
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		h.exchangeAuthorizationCode(w, r)
+	case "client_credentials":
+		h.issueClientCredentialsToken(w, r)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// exchangeAuthorizationCode serves the authorization_code grant, public clients identify themselves by their id only
+func (h *OAuthHandlerImpl) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	client, err := h.oauthService.IdentifyClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, domainerrors.ErrInvalidClient) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		} else {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	resp, err := h.oauthService.ExchangeAuthorizationCode(r.Context(), client, r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+	writeTokenResponse(w, resp, err)
+}
+
+// issueClientCredentialsToken serves the client_credentials grant, only confidential clients can use it (RFC 6749 section 4.4)
+func (h *OAuthHandlerImpl) issueClientCredentialsToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oauthService.IssueClientCredentialsToken(r.Context(), client, r.PostFormValue("scope"))
+	writeTokenResponse(w, resp, err)
+}
+
+// UserInfo returns the claims of the user the bearer token belongs to. Relying parties call it from their backend,
+// not from the user's device, so the token is validated without the session's client ip binding.
+func (h *OAuthHandlerImpl) UserInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := utils.ExtractTokenFromHeader(r)
+	if err != nil || accessToken == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="oauth"`)
+		http.Error(w, "Missing or invalid Access Token", http.StatusUnauthorized)
+		return
+	}
+	if utils.UnverifiedTokenType(accessToken) == utils.ServiceTokenType {
+		http.Error(w, "service tokens have no user info", http.StatusForbidden)
+		return
+	}
+
+	claims, _, err := h.oauthService.AuthenticateUser(r.Context(), accessToken, "")
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken),
+			errors.Is(err, domainerrors.ErrTokenRevoked),
+			errors.Is(err, domainerrors.ErrSessionNotFound):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "unauthorized access", http.StatusUnauthorized)
+		default:
+			log.Printf("oauthHandler.UserInfo: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	info, err := h.oauthService.UserInfo(r.Context(), claims.UserID, "")
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrUserNotFound):
+			http.Error(w, "user not found", http.StatusNotFound)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(info)
+}
+
+// internal functions
+func writeTokenResponse(w http.ResponseWriter, resp *userType.TokenResponse, err error) {
+	if err != nil {
+		var oauthErr *services.OAuthError
+		if errors.As(err, &oauthErr) {
+			writeOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		} else {
+			log.Printf("oauthHandler.Token: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func accessTokenFromRequest(r *http.Request) string {
+	if token, err := utils.ExtractTokenFromHeader(r); err == nil {
+		return token
+	}
+	if cookie, err := r.Cookie("access_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// redirectWithError reports an error to the client, only call it once the redirect uri is known to be registered
+func redirectWithError(w http.ResponseWriter, r *http.Request, req userType.AuthorizationRequest, code string, description string) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	http.Redirect(w, r, appendQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+// appendQuery keeps any query the registered redirect uri already has
+func appendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	model "backend-go/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+type ClientRepository interface {
+	FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error)
+	FindAll(ctx context.Context) ([]model.OAuthClient, error)
+}
+
+type clientFileRepositoryImpl struct {
+	clients map[string]model.OAuthClient
+}
+
+// NewClientRepository loads the registered clients from a JSON file holding a list of model.OAuthClient.
+// Without a file no client is registered and every client authentication fails.
+func NewClientRepository(path string) (ClientRepository, error) {
+	repo := &clientFileRepositoryImpl{
+		clients: make(map[string]model.OAuthClient),
+	}
+	if path == "" {
+		log.Println("⚠️  No OAuth clients file configured, client authentication is disabled")
+		return repo, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading oauth clients: %w", err)
+	}
+
+	var clients []model.OAuthClient
+	if err := json.Unmarshal(raw, &clients); err != nil {
+		return nil, fmt.Errorf("parsing oauth clients: %w", err)
+	}
+	for _, client := range clients {
+		if client.ClientID == "" || (client.SecretHash == "" && !client.Public) {
+			return nil, fmt.Errorf("oauth client needs a client_id and, unless public, a secret_hash")
+		}
+		if client.Public && slices.Contains(client.GrantTypes, "client_credentials") {
+			return nil, fmt.Errorf("oauth client %s: public clients can not use the client_credentials grant", client.ClientID)
+		}
+		repo.clients[client.ClientID] = client
+	}
+
+	return repo, nil
+}
+
+func (r *clientFileRepositoryImpl) FindByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, nil
+	}
+	return &client, nil
+}
+
+func (r *clientFileRepositoryImpl) FindAll(ctx context.Context) ([]model.OAuthClient, error) {
+	clients := make([]model.OAuthClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
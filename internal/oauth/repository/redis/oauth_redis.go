@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"backend-go/constants"
+	"backend-go/database/redisx"
+	rdsModel "backend-go/models/redis"
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type OAuthRedisRepository interface {
+	StoreAuthorizationCode(ctx context.Context, codeHash string, code rdsModel.AuthorizationCode) (interface{}, error)
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*rdsModel.AuthorizationCode, error)
+}
+
+type oauthCacheImpl struct {
+	redis *redisx.Client
+}
+
+func NewOAuthCache(rDb *redisx.Client) OAuthRedisRepository {
+	return &oauthCacheImpl{
+		redis: rDb,
+	}
+}
+
+// methods for OIDC authorization codes, only the hash of a code is used as key
+func (r *oauthCacheImpl) StoreAuthorizationCode(ctx context.Context, codeHash string, code rdsModel.AuthorizationCode) (interface{}, error) {
+	key := constants.AUTHORIZATION_CODE + ":" + codeHash
+
+	codeStringfy, jErr := json.Marshal(code)
+	if jErr != nil {
+		log.Printf("Error marshalling authorization code: %v", jErr)
+		return nil, jErr
+	}
+
+	if rErr := redisx.Rdb.Set(ctx, key, codeStringfy, constants.AUTHORIZATION_CODE_EXPIRATION).Err(); rErr != nil {
+		log.Printf("Failed to set authorization code in Redis: %v", rErr)
+		return nil, rErr
+	}
+
+	return nil, nil
+}
+
+// ConsumeAuthorizationCode reads and deletes the code in one step so it can only be redeemed once
+func (r *oauthCacheImpl) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*rdsModel.AuthorizationCode, error) {
+	key := constants.AUTHORIZATION_CODE + ":" + codeHash
+
+	res, err := redisx.Rdb.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to consume authorization code in Redis: %v", err)
+		return nil, err
+	}
+
+	var code rdsModel.AuthorizationCode
+	if jErr := json.Unmarshal([]byte(res), &code); jErr != nil {
+		log.Printf("Error unmarshalling authorization code: %v", jErr)
+		return nil, jErr
+	}
+
+	return &code, nil
+}
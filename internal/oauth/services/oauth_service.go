@@ -0,0 +1,168 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	repository "backend-go/internal/oauth/repository/file"
+	oauthRedisRepository "backend-go/internal/oauth/repository/redis"
+	redisRepository "backend-go/internal/user/repository/redis"
+	userServices "backend-go/internal/user/services"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"log"
+	"slices"
+)
+
+const (
+	AccessTokenHint  = "access_token"
+	RefreshTokenHint = "refresh_token"
+)
+
+type OAuthService interface {
+	AuthenticateClient(ctx context.Context, clientID string, clientSecret string) (*model.OAuthClient, error)
+	Introspect(ctx context.Context, token string, tokenTypeHint string, clientIp string) (*userType.IntrospectionResponse, error)
+	Revoke(ctx context.Context, token string, tokenTypeHint string) error
+	IdentifyClient(ctx context.Context, clientID string, clientSecret string) (*model.OAuthClient, error)
+	ValidateAuthorizationRequest(ctx context.Context, req userType.AuthorizationRequest) (*model.OAuthClient, error)
+	AuthenticateUser(ctx context.Context, accessToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error)
+	CreateAuthorizationCode(ctx context.Context, req userType.AuthorizationRequest, claims *utils.Claims, session *rdsModel.Session) (string, error)
+	ExchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, code string, redirectURI string, codeVerifier string) (*userType.TokenResponse, error)
+	IssueClientCredentialsToken(ctx context.Context, client *model.OAuthClient, scope string) (*userType.TokenResponse, error)
+	UserInfo(ctx context.Context, userId string, clientIp string) (map[string]interface{}, error)
+	Discovery() map[string]interface{}
+}
+
+type OAuthServiceImpl struct {
+	clientRepo     repository.ClientRepository
+	oauthRedisRepo oauthRedisRepository.OAuthRedisRepository
+	redisRepo      redisRepository.UserRedisRepository
+	tokenValidator userServices.TokenValidator
+	userService    userServices.UserService
+}
+
+func NewOAuthService(clientRepo repository.ClientRepository, oauthRedisRepo oauthRedisRepository.OAuthRedisRepository, redisRepo redisRepository.UserRedisRepository, tokenValidator userServices.TokenValidator, userService userServices.UserService) *OAuthServiceImpl {
+	return &OAuthServiceImpl{
+		clientRepo:     clientRepo,
+		oauthRedisRepo: oauthRedisRepo,
+		redisRepo:      redisRepo,
+		tokenValidator: tokenValidator,
+		userService:    userService,
+	}
+}
+
+func (s *OAuthServiceImpl) AuthenticateClient(ctx context.Context, clientID string, clientSecret string) (*model.OAuthClient, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, domainerrors.ErrInvalidClient
+	}
+
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || !utils.SecureCompare(client.SecretHash, utils.HashToken(clientSecret)) {
+		log.Printf("oauthService.AuthenticateClient: client authentication failed for %s", clientID)
+		return nil, domainerrors.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// CanInspectTokens reports whether the client is a service allowed to introspect and revoke tokens of any user:
+// a confidential client with the token:introspect scope registered
+func CanInspectTokens(client *model.OAuthClient) bool {
+	return !client.Public && slices.Contains(client.Scopes, constants.SCOPE_TOKEN_INTROSPECT)
+}
+
+// Introspect runs the same checks as AuthMiddleware without side effects. Tokens failing any of them are reported as inactive.
+func (s *OAuthServiceImpl) Introspect(ctx context.Context, token string, tokenTypeHint string, clientIp string) (*userType.IntrospectionResponse, error) {
+	for _, tokenType := range tokenTypesByHint(tokenTypeHint) {
+		var claims *utils.Claims
+		var err error
+		switch tokenType {
+		case utils.AccessTokenType:
+			claims, _, err = s.tokenValidator.ValidateAccessToken(ctx, token, clientIp)
+		case utils.ServiceTokenType:
+			claims, err = s.tokenValidator.ValidateServiceToken(ctx, token)
+		default:
+			claims, _, err = s.tokenValidator.InspectRefreshToken(ctx, token, clientIp)
+		}
+		if err != nil {
+			if isInactiveTokenErr(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		return &userType.IntrospectionResponse{
+			Active:    true,
+			TokenType: hintOf(tokenType),
+			Username:  claims.Email,
+			Subject:   claims.Subject,
+			Audience:  claims.Audience,
+			Issuer:    claims.Issuer,
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			IssuedAt:  claims.IssuedAt.Unix(),
+			TokenID:   claims.ID,
+			SessionID: claims.SessionID,
+			ClientID:  claims.ClientID,
+			Scope:     claims.Scope,
+		}, nil
+	}
+
+	return &userType.IntrospectionResponse{Active: false}, nil
+}
+
+// Revoke invalidates an access or service token through the denylist, or a refresh token together with its whole session.
+// Unknown or invalid tokens are not an error (RFC 7009 section 2.2).
+func (s *OAuthServiceImpl) Revoke(ctx context.Context, token string, tokenTypeHint string) error {
+	for _, tokenType := range tokenTypesByHint(tokenTypeHint) {
+		claims, err := utils.VerifyAndParseJWTToken(token, tokenType)
+		if err != nil {
+			continue
+		}
+
+		if tokenType != utils.RefreshTokenType {
+			_, err = s.redisRepo.SetBlacklistOfAccessToken(ctx, claims.ID, claims.ExpiresAt.Time)
+			return err
+		}
+
+		session, err := s.redisRepo.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			return err
+		}
+		if session == nil || session.UserID != claims.UserID {
+			return nil
+		}
+		_, err = s.redisRepo.DeleteSession(ctx, session.UserID, session.SessionID)
+		return err
+	}
+
+	return nil
+}
+
+// tokenTypesByHint orders the token types to try, the hint only speeds up the lookup (RFC 7662 section 2.1)
+func tokenTypesByHint(tokenTypeHint string) []utils.TokenType {
+	if tokenTypeHint == RefreshTokenHint {
+		return []utils.TokenType{utils.RefreshTokenType, utils.AccessTokenType, utils.ServiceTokenType}
+	}
+	return []utils.TokenType{utils.AccessTokenType, utils.ServiceTokenType, utils.RefreshTokenType}
+}
+
+func hintOf(tokenType utils.TokenType) string {
+	if tokenType == utils.RefreshTokenType {
+		return RefreshTokenHint
+	}
+	return AccessTokenHint
+}
+
+func isInactiveTokenErr(err error) bool {
+	return errors.Is(err, domainerrors.ErrInvalidToken) ||
+		errors.Is(err, domainerrors.ErrTokenRevoked) ||
+		errors.Is(err, domainerrors.ErrRefreshTokenReused) ||
+		errors.Is(err, domainerrors.ErrSessionNotFound) ||
+		errors.Is(err, domainerrors.ErrClientIPMismatch)
+}
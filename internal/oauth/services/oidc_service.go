@@ -0,0 +1,290 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const AUTHORIZATION_CODE_BYTES = 32
+
+var SupportedScopes = []string{"openid", "email", "profile"}
+
+// undelegatableScopes stay with the user's own login sessions
+var undelegatableScopes = []string{constants.PERMISSION_ACCOUNT_MANAGE, "*"}
+
+// OAuthError is an error the client is told about through the error and error_description parameters (RFC 6749 section 4.1.2.1)
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// IdentifyClient authenticates confidential clients by their secret, public clients only by their id
+func (s *OAuthServiceImpl) IdentifyClient(ctx context.Context, clientID string, clientSecret string) (*model.OAuthClient, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domainerrors.ErrInvalidClient
+	}
+	if client.Public {
+		return client, nil
+	}
+
+	return s.AuthenticateClient(ctx, clientID, clientSecret)
+}
+
+// CheckIDTokenSigning refuses clients of the authorization code flow unless ID tokens can be signed with an asymmetric key,
+// relying parties verify them against the published JWKS
+func (s *OAuthServiceImpl) CheckIDTokenSigning(ctx context.Context) error {
+	clients, err := s.clientRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		if !allowsGrant(&client, "authorization_code") {
+			continue
+		}
+		if _, err := utils.IDTokenSigningKey(); err != nil {
+			return fmt.Errorf("oauth client %s uses the authorization code flow: %w", client.ClientID, err)
+		}
+	}
+	return nil
+}
+
+// ValidateAuthorizationRequest checks the client and its redirect uri first, errors about them must never be redirected.
+// Other problems come back as *OAuthError and are reported to the client's redirect uri.
+func (s *OAuthServiceImpl) ValidateAuthorizationRequest(ctx context.Context, req userType.AuthorizationRequest) (*model.OAuthClient, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domainerrors.ErrInvalidClient
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, domainerrors.ErrInvalidRedirect
+	}
+
+	if !allowsGrant(client, "authorization_code") {
+		return client, &OAuthError{Code: "unauthorized_client", Description: "the client may not use the authorization code flow"}
+	}
+	if req.ResponseType != "code" {
+		return client, &OAuthError{Code: "unsupported_response_type", Description: "only the authorization code flow is supported"}
+	}
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, "openid") {
+		return client, &OAuthError{Code: "invalid_scope", Description: "the openid scope is required"}
+	}
+	for _, scope := range scopes {
+		// besides the OpenID Connect scopes a client may ask for the permissions registered for it
+		if slices.Contains(undelegatableScopes, scope) || (!slices.Contains(SupportedScopes, scope) && !slices.Contains(client.Scopes, scope)) {
+			return client, &OAuthError{Code: "invalid_scope", Description: "unsupported scope " + scope}
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return client, &OAuthError{Code: "invalid_request", Description: "PKCE with code_challenge_method S256 is required"}
+	}
+
+	return client, nil
+}
+
+// AuthenticateUser resolves the user's login session from the access token the browser presents to /oauth/authorize
+func (s *OAuthServiceImpl) AuthenticateUser(ctx context.Context, accessToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error) {
+	return s.tokenValidator.ValidateAccessToken(ctx, accessToken, clientIp)
+}
+
+// CreateAuthorizationCode issues a short lived, single use code bound to the user's session and the PKCE challenge
+func (s *OAuthServiceImpl) CreateAuthorizationCode(ctx context.Context, req userType.AuthorizationRequest, claims *utils.Claims, session *rdsModel.Session) (string, error) {
+	code, err := utils.GenerateSecureToken(AUTHORIZATION_CODE_BYTES)
+	if err != nil {
+		return "", err
+	}
+
+	authCode := rdsModel.AuthorizationCode{
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		UserID:        claims.UserID,
+		Email:         claims.Email,
+		Role:          claims.Role,
+		SessionID:     session.SessionID,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      session.CreatedAt,
+	}
+	if _, err := s.oauthRedisRepo.StoreAuthorizationCode(ctx, utils.HashToken(code), authCode); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeAuthorizationCode redeems a code at the token endpoint. The access token is bound to the session the user authorized with,
+// so signing out of that session also ends the client's access.
+func (s *OAuthServiceImpl) ExchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, code string, redirectURI string, codeVerifier string) (*userType.TokenResponse, error) {
+	if !allowsGrant(client, "authorization_code") {
+		return nil, &OAuthError{Code: "unauthorized_client", Description: "the client may not use the authorization_code grant"}
+	}
+
+	authCode, err := s.oauthRedisRepo.ConsumeAuthorizationCode(ctx, utils.HashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if authCode == nil || authCode.ClientID != client.ClientID || authCode.RedirectURI != redirectURI {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "authorization code is invalid or expired"}
+	}
+	if !utils.VerifyPKCE(codeVerifier, authCode.CodeChallenge) {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "code_verifier does not match the code_challenge"}
+	}
+
+	session, err := s.redisRepo.GetSession(ctx, authCode.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != authCode.UserID {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "the user session has ended"}
+	}
+
+	scope := delegatedScope(authCode.Role, session.Scope, authCode.Scope)
+	accessToken, errAccessToken := utils.GenerateAccessToken(authCode.UserID, authCode.Email, authCode.Role, authCode.SessionID, strings.Join(scope.permissions, " "))
+	idToken, errIDToken := utils.GenerateIDToken(authCode.UserID, authCode.Email, client.ClientID, authCode.Nonce, time.Unix(authCode.AuthTime, 0))
+	if errAccessToken != nil || errIDToken != nil {
+		log.Printf("oauthService.ExchangeAuthorizationCode: error generating tokens: %v %v", errAccessToken, errIDToken)
+		return nil, domainerrors.ErrGeneratingJWTToken
+	}
+
+	return &userType.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   constants.ACCESS_TOKEN_EXPIRATION_IN_SECONDS,
+		IDToken:     idToken,
+		Scope:       strings.Join(append(scope.openID, scope.permissions...), " "),
+	}, nil
+}
+
+// IssueClientCredentialsToken issues a token for the client itself. The requested scopes must all be registered for the client,
+// without a scope parameter the token gets every registered scope. There is no refresh token (RFC 6749 section 4.4.3).
+func (s *OAuthServiceImpl) IssueClientCredentialsToken(ctx context.Context, client *model.OAuthClient, scope string) (*userType.TokenResponse, error) {
+	if client.Public || !allowsGrant(client, "client_credentials") {
+		return nil, &OAuthError{Code: "unauthorized_client", Description: "the client may not use the client_credentials grant"}
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, requested := range scopes {
+		if !slices.Contains(client.Scopes, requested) {
+			return nil, &OAuthError{Code: "invalid_scope", Description: "scope " + requested + " is not registered for the client"}
+		}
+	}
+	granted := strings.Join(scopes, " ")
+
+	accessToken, err := utils.GenerateServiceToken(client.ClientID, granted)
+	if err != nil {
+		log.Printf("oauthService.IssueClientCredentialsToken: error generating token: %v", err)
+		return nil, domainerrors.ErrGeneratingJWTToken
+	}
+	utils.LogSecurityEvent("oauth_client_token_issued", "client %s was issued a service token with scope %q", client.ClientID, granted)
+
+	return &userType.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(constants.SERVICE_TOKEN_EXPIRATION.Seconds()),
+		Scope:       granted,
+	}, nil
+}
+
+// UserInfo returns the standard claims of the user (OpenID Connect Core section 5.3)
+func (s *OAuthServiceImpl) UserInfo(ctx context.Context, userId string, clientIp string) (map[string]interface{}, error) {
+	user, err := s.userService.Profile(ctx, userId, clientIp)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"sub":   user.ID,
+		"email": user.Email,
+		"role":  user.Role,
+	}, nil
+}
+
+// Discovery builds the OpenID Provider metadata served at /.well-known/openid-configuration
+func (s *OAuthServiceImpl) Discovery() map[string]interface{} {
+	base := strings.TrimRight(constants.OIDC_ISSUER, "/")
+
+	algs := []string{}
+	if key, err := utils.ActiveSigningKey(); err == nil {
+		algs = append(algs, key.Method.Alg())
+	}
+
+	return map[string]interface{}{
+		"issuer":                                constants.OIDC_ISSUER, // must match the iss of ID tokens exactly
+		"authorization_endpoint":                base + "/oauth/authorize",
+		"token_endpoint":                        base + "/oauth/token",
+		"userinfo_endpoint":                     base + "/oauth/userinfo",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"introspection_endpoint":                base + "/oauth/introspect",
+		"revocation_endpoint":                   base + "/oauth/revoke",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": algs,
+		"scopes_supported":                      SupportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email"},
+	}
+}
+
+// allowsGrant checks the grant types registered for the client, clients registered without any use the authorization code flow
+func allowsGrant(client *model.OAuthClient, grantType string) bool {
+	if len(client.GrantTypes) == 0 {
+		return grantType == "authorization_code"
+	}
+	return slices.Contains(client.GrantTypes, grantType)
+}
+
+type grantedScope struct {
+	openID      []string // OpenID Connect scopes, they only decide what the ID token and userinfo contain
+	permissions []string // scopes the access token carries
+}
+
+// delegatedScope splits the authorized scope. A client never gets a permission the user's role or the scope of
+// the login session it was authorized from does not grant, and without asking for permissions it gets none.
+// Managing the account and the "*" wildcard are never delegated.
+func delegatedScope(role string, sessionScope string, requested string) grantedScope {
+	sessionScopes := utils.GrantScopes(role, strings.Fields(sessionScope))
+
+	var scope grantedScope
+	var permissions []string
+	for _, requestedScope := range strings.Fields(requested) {
+		if slices.Contains(SupportedScopes, requestedScope) {
+			scope.openID = append(scope.openID, requestedScope)
+		} else if !slices.Contains(undelegatableScopes, requestedScope) {
+			permissions = append(permissions, requestedScope)
+		}
+	}
+	for _, permission := range utils.CurrentRBACPolicy().CapScopes(role, permissions) {
+		if utils.HasScope(sessionScopes, permission) {
+			scope.permissions = append(scope.permissions, permission)
+		}
+	}
+
+	return scope
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"backend-go/constants"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegatedScope(t *testing.T) {
+	// a login that did not ask for a scope has everything the role grants, account:manage included
+	scope := delegatedScope(constants.ROLE_USER, "", "openid email profile:read account:manage")
+	assert.Equal(t, []string{"openid", "email"}, scope.openID)
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, scope.permissions, "managing the account is never delegated")
+
+	scope = delegatedScope(constants.ROLE_ADMIN, "", "openid * users:read")
+	assert.Equal(t, []string{constants.PERMISSION_USERS_READ}, scope.permissions, "neither is the wildcard of admins")
+
+	scope = delegatedScope(constants.ROLE_USER, constants.PERMISSION_PROFILE_READ, "openid profile:read profile:write")
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, scope.permissions, "capped by the scope of the login session")
+
+	assert.Empty(t, delegatedScope(constants.ROLE_USER, "", "openid").permissions)
+}
@@ -1,14 +1,19 @@
 package app
 
 import (
+	"backend-go/config"
 	"backend-go/constants"
 	"backend-go/database/redisx"
 	handlers "backend-go/internal/user/handler"
 	repository "backend-go/internal/user/repository/mongoDb"
 	redisRepository "backend-go/internal/user/repository/redis"
 	"backend-go/internal/user/services"
+	"backend-go/mailer"
 	middleware "backend-go/middlewares"
 	rdsModel "backend-go/models/redis"
+	"backend-go/utils"
+	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -17,12 +22,15 @@ import (
 )
 
 type App struct {
-	DB            *mongo.Database
-	redisDB       *redisx.Client
-	UserMangoRepo repository.UserRepository
-	UserRedisRepo redisRepository.UserRedisRepository
-	UserService   services.UserService
-	UserHandler   handlers.UserHandler
+	DB             *mongo.Database
+	redisDB        *redisx.Client
+	UserMangoRepo  repository.UserRepository
+	UserRedisRepo  redisRepository.UserRedisRepository
+	UserService    services.UserService
+	TokenValidator services.TokenValidator
+	UserHandler    handlers.UserHandler
+	APIKeyRepo     repository.APIKeyRepository
+	APIKeyHandler  handlers.APIKeyHandler
 }
 
 // NewApp initializes everything in one place
@@ -30,17 +38,64 @@ func NewApp(mongoDB *mongo.Database, redisDB *redisx.Client) (*App, error) {
 	//Setup repositories,services,handlers
 	mongoRepo := repository.NewUserRepository(mongoDB)
 	redisRepo := redisRepository.NewUserCache(redisDB)
+	apiKeyRepo := repository.NewAPIKeyRepository(mongoDB)
 
-	service := services.NewUserService(mongoRepo, redisRepo)
+	// accounts from before email verification existed have no email_verified field, they count as verified
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	migrated, err := mongoRepo.MarkLegacyEmailsVerified(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if migrated > 0 {
+		log.Printf("marked %d existing accounts as email verified", migrated)
+	}
+
+	// external identity providers are optional
+	providers := map[string]*utils.OIDCProvider{}
+	if path := config.GetEnv("OIDC_PROVIDERS_FILE", ""); path != "" {
+		loaded, err := utils.LoadOIDCProvidersFile(path)
+		if err != nil {
+			return nil, err
+		}
+		providers = loaded
+	}
+
+	hashParams, err := utils.NewArgon2idParams(constants.PASSWORD_HASH_MEMORY_KIB, constants.PASSWORD_HASH_ITERATIONS,
+		constants.PASSWORD_HASH_PARALLELISM, constants.PASSWORD_HASH_SALT_BYTES, constants.PASSWORD_HASH_KEY_BYTES)
+	if err != nil {
+		return nil, err
+	}
+	hasher := services.NewPasswordHasher(hashParams)
+
+	passwordPolicy := services.NewPasswordPolicy(utils.PasswordRules{
+		MinLength:     constants.PASSWORD_MIN_LENGTH,
+		MaxLength:     constants.PASSWORD_MAX_LENGTH,
+		RequireUpper:  constants.PASSWORD_REQUIRE_UPPER,
+		RequireLower:  constants.PASSWORD_REQUIRE_LOWER,
+		RequireDigit:  constants.PASSWORD_REQUIRE_DIGIT,
+		RequireSymbol: constants.PASSWORD_REQUIRE_SYMBOL,
+		DisallowEmail: constants.PASSWORD_DISALLOW_EMAIL,
+	}, &utils.BreachedPasswords{
+		Dir:      constants.BREACHED_PASSWORDS_DIR,
+		MinCount: constants.BREACHED_PASSWORD_MIN_COUNT,
+	})
+
+	service := services.NewUserService(mongoRepo, redisRepo, providers, mailer.NewMailer(), hasher, passwordPolicy)
+	tokenValidator := services.NewTokenValidator(redisRepo, apiKeyRepo, mongoRepo)
 	handler := handlers.NewUserHandler(service)
+	apiKeyHandler := handlers.NewAPIKeyHandler(services.NewAPIKeyService(apiKeyRepo, mongoRepo))
 
 	return &App{
-		DB:            mongoDB,
-		redisDB:       redisDB,
-		UserMangoRepo: mongoRepo,
-		UserRedisRepo: redisRepo,
-		UserService:   service,
-		UserHandler:   handler,
+		DB:             mongoDB,
+		redisDB:        redisDB,
+		UserMangoRepo:  mongoRepo,
+		UserRedisRepo:  redisRepo,
+		UserService:    service,
+		TokenValidator: tokenValidator,
+		UserHandler:    handler,
+		APIKeyRepo:     apiKeyRepo,
+		APIKeyHandler:  apiKeyHandler,
 	}, nil
 }
 
@@ -56,12 +111,28 @@ func (a *App) RegisterRoutes(r *mux.Router) {
 	rl := middleware.NewRateLimiter(a.redisDB, defaultCfg)
 
 	//specific route config
-	rl.AddRouteLimit("/api/user/login", rdsModel.RateLimitConfig{
+	// every way to log in, or to get a login or reset link emailed, shares the login limits
+	var loginCfg = rdsModel.RateLimitConfig{
 		RateLimit:       constants.LOGIN_RATE_LIMITER_RATE,
 		BurstLimit:      constants.LOGIN_RATE_LIMITER_BURST,
 		RemainingTokens: constants.LOGIN_RATE_LIMITER_BURST - 1,
 		TTL:             constants.GLOBAL_RATE_LIMITER_TTL,
 		LastRefill:      time.Now(),
+	}
+	rl.AddRouteLimit("/api/user/login", loginCfg)
+	rl.AddRouteLimit("/api/user/login/mfa", loginCfg)
+	rl.AddRouteLimit("/api/user/login/magic-link", loginCfg)
+	rl.AddRouteLimit("/api/user/login/magic-link/verify", loginCfg)
+	rl.AddRouteLimit("/api/user/password/forgot", loginCfg)
+	rl.AddRouteLimit("/api/user/password/change", loginCfg)
+	rl.AddRouteLimit("/api/user/email/change", loginCfg)
+	rl.AddRouteLimit("/api/user/email/change/approve", loginCfg)
+	rl.AddRouteLimit("/api/user/email/verify/resend", rdsModel.RateLimitConfig{
+		RateLimit:       constants.EMAIL_VERIFICATION_RATE_LIMITER_RATE,
+		BurstLimit:      constants.EMAIL_VERIFICATION_RATE_LIMITER_BURST,
+		RemainingTokens: constants.EMAIL_VERIFICATION_RATE_LIMITER_BURST - 1,
+		TTL:             constants.GLOBAL_RATE_LIMITER_TTL,
+		LastRefill:      time.Now(),
 	})
 	rl.AddRouteLimit("/api/user/profile", rdsModel.RateLimitConfig{
 		RateLimit:       constants.PROFILE_RATE_LIMITER_RATE,
@@ -75,7 +146,40 @@ func (a *App) RegisterRoutes(r *mux.Router) {
 
 	r.HandleFunc("/register", a.UserHandler.RegisterUser).Methods("POST")
 	r.HandleFunc("/login", a.UserHandler.LoginUser).Methods("POST")
-	r.Handle("/profile", middleware.AuthMiddleware(http.HandlerFunc(a.UserHandler.Profile), a.UserRedisRepo)).Methods("GET")
-	r.Handle("/logout", middleware.AuthMiddleware(http.HandlerFunc(a.UserHandler.LogoutUser), a.UserRedisRepo)).Methods("POST")
-	r.Handle("/access-token", middleware.RefreshAuthMiddleware(http.HandlerFunc(a.UserHandler.GetSilentAccesToken), a.UserRedisRepo)).Methods("GET")
+	r.HandleFunc("/login/mfa", a.UserHandler.VerifyMFA).Methods("POST")
+	r.HandleFunc("/login/magic-link", a.UserHandler.RequestMagicLink).Methods("POST")
+	r.HandleFunc("/login/magic-link/verify", a.UserHandler.LoginWithMagicLink).Methods("POST")
+	r.Handle("/mfa/enroll", a.manageAccount(a.UserHandler.EnrollMFA)).Methods("POST")
+	r.Handle("/mfa/confirm", a.manageAccount(a.UserHandler.ConfirmMFA)).Methods("POST")
+	r.HandleFunc("/email/verify", a.UserHandler.VerifyEmail).Methods("POST")
+	r.HandleFunc("/email/verify/resend", a.UserHandler.ResendVerificationEmail).Methods("POST")
+	r.HandleFunc("/password/forgot", a.UserHandler.RequestPasswordReset).Methods("POST")
+	r.HandleFunc("/password/reset", a.UserHandler.ResetPassword).Methods("POST")
+	r.Handle("/password/change", a.manageAccount(a.UserHandler.ChangePassword)).Methods("POST")
+	r.Handle("/email/change", a.manageAccount(a.UserHandler.RequestEmailChange)).Methods("POST")
+	r.HandleFunc("/email/change/approve", a.UserHandler.ApproveEmailChange).Methods("POST")
+	r.HandleFunc("/email/change/confirm", a.UserHandler.ConfirmEmailChange).Methods("POST")
+	r.HandleFunc("/oidc/{provider}/login", a.UserHandler.ExternalLogin).Methods("GET")
+	r.HandleFunc("/oidc/{provider}/callback", a.UserHandler.ExternalLoginCallback).Methods("GET")
+	r.Handle("/profile", middleware.AuthMiddleware(middleware.RequirePermission(constants.PERMISSION_PROFILE_READ)(http.HandlerFunc(a.UserHandler.Profile)), a.TokenValidator)).Methods("GET")
+	r.Handle("/logout", a.sessionOnly(a.UserHandler.LogoutUser)).Methods("POST")
+	r.Handle("/sessions", a.manageAccount(a.UserHandler.ListSessions)).Methods("GET")
+	r.Handle("/sessions", a.sessionOnly(a.UserHandler.RevokeOtherSessions)).Methods("DELETE")
+	r.Handle("/sessions/{sessionId}", a.sessionOnly(a.UserHandler.RevokeSession)).Methods("DELETE")
+	r.Handle("/access-token", middleware.RefreshAuthMiddleware(http.HandlerFunc(a.UserHandler.GetSilentAccesToken), a.TokenValidator, a.UserRedisRepo)).Methods("GET")
+	r.Handle("/api-keys", a.manageAccount(a.APIKeyHandler.CreateAPIKey)).Methods("POST")
+	r.Handle("/api-keys", a.manageAccount(a.APIKeyHandler.ListAPIKeys)).Methods("GET")
+	r.Handle("/api-keys/{keyId}", a.manageAccount(a.APIKeyHandler.RevokeAPIKey)).Methods("DELETE")
+}
+
+// sessionOnly protects the routes that sign out, they need a login session so neither API keys nor tokens delegated
+// to OAuth clients can call them. Every login may end its sessions, also one that did not ask for account:manage.
+func (a *App) sessionOnly(handler http.HandlerFunc) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequireSession(handler), a.TokenValidator)
+}
+
+// manageAccount protects the routes that change credentials, MFA or API keys, or list the sessions.
+// Besides a login session they need a token that carries account:manage.
+func (a *App) manageAccount(handler http.HandlerFunc) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequireSession(middleware.RequireScope(constants.PERMISSION_ACCOUNT_MANAGE)(handler)), a.TokenValidator)
 }
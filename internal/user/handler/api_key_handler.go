@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	contextkeys "backend-go/contextKeys"
+	"backend-go/internal/user/services"
+	userType "backend-go/type"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler interface {
+	CreateAPIKey(w http.ResponseWriter, r *http.Request)
+	ListAPIKeys(w http.ResponseWriter, r *http.Request)
+	RevokeAPIKey(w http.ResponseWriter, r *http.Request)
+}
+
+type APIKeyHandlerImpl struct {
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyHandler(s services.APIKeyService) *APIKeyHandlerImpl {
+	return &APIKeyHandlerImpl{
+		apiKeyService: s,
+	}
+}
+
+// CreateAPIKey answers with the key itself, it can not be shown again
+func (h *APIKeyHandlerImpl) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	var req userType.APIKeyRequest
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := h.apiKeyService.CreateAPIKey(ctx, userContent.Claims.UserID, userContent.Claims.Scopes(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidScope):
+			writeFieldErrors(w, []userType.FieldError{{Field: "scopes", Code: "invalid", Message: "must be one or more permissions of your role and your login"}})
+		case errors.Is(err, domainerrors.ErrInvalidAPIKeyName):
+			writeFieldErrors(w, []userType.FieldError{{Field: "name", Code: "invalid", Message: fmt.Sprintf("must be 1 to %d characters", constants.API_KEY_NAME_MAX_LENGTH)}})
+		case errors.Is(err, domainerrors.ErrInvalidAPIKeyExpiry):
+			writeFieldErrors(w, []userType.FieldError{{Field: "expires_in_days", Code: "invalid", Message: "must not be negative"}})
+		case errors.Is(err, domainerrors.ErrTooManyAPIKeys):
+			http.Error(w, "Too many API keys, revoke one first", http.StatusConflict)
+		default:
+			log.Printf("apiKeyHandler.CreateAPIKey: %v", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *APIKeyHandlerImpl) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(r.Context(), userContent.Claims.UserID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"api_keys": keys})
+}
+
+func (h *APIKeyHandlerImpl) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(r.Context(), userContent.Claims.UserID, mux.Vars(r)["keyId"]); err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrAPIKeyNotFound):
+			http.Error(w, "API key not found", http.StatusNotFound)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked"})
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	domainerrors "backend-go/constants/errors"
+	contextkeys "backend-go/contextKeys"
+	"backend-go/internal/user/services"
+	userType "backend-go/type"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (h *UserHandlerImpl) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := h.userService.ChangePassword(ctx, userContent.Claims.UserID, userContent.Claims.SessionID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		writeCredentialsError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password changed, your other sessions were signed out",
+		"revoked": revoked,
+	})
+}
+
+func (h *UserHandlerImpl) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		NewEmail string `json:"new_email"`
+		Password string `json:"password"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NewEmail == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(req.NewEmail, "@") {
+		writeFieldErrors(w, []userType.FieldError{{Field: "new_email", Code: "invalid", Message: "must be an email address"}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.userService.RequestEmailChange(ctx, userContent.Claims.UserID, req.Password, req.NewEmail, getClientInfo(r, "")); err != nil {
+		writeCredentialsError(w, err)
+		return
+	}
+
+	// accounts without a password get the first link at their current address
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Open the emailed link to confirm the change"})
+}
+
+// ApproveEmailChange needs no session, the token emailed to the current address identifies the account
+func (h *UserHandlerImpl) ApproveEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.userService.ApproveEmailChange(ctx, req.Token); err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken), errors.Is(err, domainerrors.ErrUserNotFound):
+			http.Error(w, "Approval link is invalid or expired", http.StatusBadRequest)
+		default:
+			writeCredentialsError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Open the link sent to the new address to confirm the change"})
+}
+
+// ConfirmEmailChange needs no session, the emailed token identifies the account
+func (h *UserHandlerImpl) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.userService.ConfirmEmailChange(ctx, req.Token); err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken), errors.Is(err, domainerrors.ErrUserNotFound):
+			http.Error(w, "Confirmation link is invalid or expired", http.StatusBadRequest)
+		default:
+			writeCredentialsError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email address changed, sign in again with the new address"})
+}
+
+func writeCredentialsError(w http.ResponseWriter, err error) {
+	var blocked *services.LoginBlockedError
+	var policyErr *services.PasswordPolicyError
+	switch {
+	case errors.As(err, &blocked):
+		writeLoginBlocked(w, blocked)
+	case errors.As(err, &policyErr):
+		writeFieldErrors(w, policyErr.Fields)
+	case errors.Is(err, domainerrors.ErrInvalidCredentials):
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+	case errors.Is(err, domainerrors.ErrEmailTaken):
+		http.Error(w, "Email is already in use", http.StatusConflict)
+	case errors.Is(err, domainerrors.ErrUserNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
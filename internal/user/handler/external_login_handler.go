@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// ExternalLogin redirects the user to the identity provider named in the url
+func (h *UserHandlerImpl) ExternalLogin(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authURL, state, err := h.userService.StartExternalLogin(ctx, provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrUnknownProvider):
+			http.Error(w, "unknown identity provider", http.StatusNotFound)
+		case errors.Is(err, domainerrors.ErrExternalLoginFailed):
+			http.Error(w, "identity provider is not available", http.StatusBadGateway)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// the callback only completes in the browser that started the login
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(constants.OIDC_LOGIN_STATE_EXPIRATION.Seconds()),
+		Path:     "/",
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ExternalLoginCallback is the redirect uri registered at the identity provider
+func (h *UserHandlerImpl) ExternalLoginCallback(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		HttpOnly: true,
+		MaxAge:   -1,
+		Path:     "/",
+	})
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("userHandler.ExternalLoginCallback: %s returned %s", provider, providerErr)
+		http.Error(w, "login was cancelled or denied", http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || query.Get("code") == "" || !utils.SecureCompare(cookie.Value, state) {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := getClientInfo(r, "")
+
+	userRes, err := h.userService.CompleteExternalLogin(ctx, provider, query.Get("code"), state, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrUnknownProvider):
+			http.Error(w, "unknown identity provider", http.StatusNotFound)
+		case errors.Is(err, domainerrors.ErrExternalLoginFailed):
+			http.Error(w, "external login failed", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrEmailNotVerified):
+			http.Error(w, "email is not verified by the identity provider", http.StatusForbidden)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeLoginResponse(w, userRes)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	domainerrors "backend-go/constants/errors"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RequestMagicLink answers the same way whether the email belongs to an account or not
+func (h *UserHandlerImpl) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email       string `json:"email"`
+		DeviceLabel string `json:"device_label"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.userService.RequestMagicLink(ctx, req.Email, getClientInfo(r, req.DeviceLabel)); err != nil {
+		log.Printf("userHandler.RequestMagicLink: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If an account exists for this email, a login link has been sent"})
+}
+
+func (h *UserHandlerImpl) LoginWithMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		DeviceLabel string `json:"device_label"`
+		Scope       string `json:"scope"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := getClientInfo(r, req.DeviceLabel)
+	client.Scope = req.Scope
+
+	userRes, err := h.userService.LoginWithMagicLink(ctx, req.Token, client)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken), errors.Is(err, domainerrors.ErrUserNotFound):
+			http.Error(w, "Login link is invalid or expired, or was opened on another device", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		case errors.Is(err, domainerrors.ErrInvalidScope):
+			http.Error(w, "None of the requested scopes is granted to the account", http.StatusBadRequest)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeLoginResponse(w, userRes)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	domainerrors "backend-go/constants/errors"
+	contextkeys "backend-go/contextKeys"
+	"backend-go/internal/user/services"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// VerifyMFA is the second step of a login for users with two factor authentication
+func (h *UserHandlerImpl) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req userType.MFARequest
+	w.Header().Set("Content-Type", "application/json")
+	payloadErr := json.NewDecoder(r.Body).Decode(&req)
+	if payloadErr != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := getClientInfo(r, req.DeviceLabel)
+
+	userRes, err := h.userService.VerifyMFA(ctx, req, client)
+	if err != nil {
+		var blocked *services.LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			writeLoginBlocked(w, blocked)
+		case errors.Is(err, domainerrors.ErrInvalidToken):
+			http.Error(w, "MFA challenge is invalid or expired, log in again", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrInvalidMFACode):
+			http.Error(w, "Invalid two factor code", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrTooManyMFAAttempts):
+			http.Error(w, "Too many attempts, log in again", http.StatusTooManyRequests)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		case errors.Is(err, domainerrors.ErrInvalidScope):
+			http.Error(w, "None of the requested scopes is granted to the account", http.StatusBadRequest)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeLoginResponse(w, userRes)
+}
+
+func (h *UserHandlerImpl) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.userService.EnrollMFA(r.Context(), userContent.Claims.UserID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *UserHandlerImpl) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.userService.ConfirmMFA(r.Context(), userContent.Claims.UserID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two factor authentication enabled, store the recovery codes in a safe place",
+		"recovery_codes": codes,
+	})
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domainerrors.ErrUserNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, domainerrors.ErrMFAAlreadyEnabled):
+		http.Error(w, "Two factor authentication is already enabled", http.StatusConflict)
+	case errors.Is(err, domainerrors.ErrMFANotEnrolled):
+		http.Error(w, "Start the enrolment first", http.StatusBadRequest)
+	case errors.Is(err, domainerrors.ErrInvalidMFACode):
+		http.Error(w, "Invalid two factor code", http.StatusBadRequest)
+	case errors.Is(err, domainerrors.ErrTooManyMFAAttempts):
+		http.Error(w, "Too many attempts, try again later", http.StatusTooManyRequests)
+	case errors.Is(err, utils.ErrEncryptionKeyMissing):
+		http.Error(w, "Two factor authentication is not available", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
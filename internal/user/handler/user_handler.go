@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"backend-go/config"
 	"backend-go/constants"
 	domainerrors "backend-go/constants/errors"
 	contextkeys "backend-go/contextKeys"
@@ -13,10 +12,12 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/gorilla/mux"
 )
 
 type UserHandler interface {
@@ -25,6 +26,24 @@ type UserHandler interface {
 	LogoutUser(w http.ResponseWriter, r *http.Request)
 	Profile(w http.ResponseWriter, r *http.Request)
 	GetSilentAccesToken(w http.ResponseWriter, r *http.Request)
+	ListSessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+	RevokeOtherSessions(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	RequestEmailChange(w http.ResponseWriter, r *http.Request)
+	ApproveEmailChange(w http.ResponseWriter, r *http.Request)
+	ConfirmEmailChange(w http.ResponseWriter, r *http.Request)
+	VerifyMFA(w http.ResponseWriter, r *http.Request)
+	EnrollMFA(w http.ResponseWriter, r *http.Request)
+	ConfirmMFA(w http.ResponseWriter, r *http.Request)
+	RequestMagicLink(w http.ResponseWriter, r *http.Request)
+	LoginWithMagicLink(w http.ResponseWriter, r *http.Request)
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerificationEmail(w http.ResponseWriter, r *http.Request)
+	RequestPasswordReset(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+	ExternalLogin(w http.ResponseWriter, r *http.Request)
+	ExternalLoginCallback(w http.ResponseWriter, r *http.Request)
 }
 
 type UserHandlerImpl struct {
@@ -47,24 +66,25 @@ func (h *UserHandlerImpl) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-
-	// Hash password
-	hashed, _ := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
-	creds.Password = string(hashed)
 	defer cancel()
 
 	_, err := h.userService.Register(ctx, creds)
 	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			writeFieldErrors(w, policyErr.Fields)
+			return
+		}
 		http.Error(w, "User already exists or DB error", http.StatusBadRequest)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully, check your email to verify the address"})
 }
 
 func (h *UserHandlerImpl) LoginUser(w http.ResponseWriter, r *http.Request) {
-	var creds model.User
+	var creds userType.LoginRequest
 	w.Header().Set("Content-Type", "application/json")
 	payloadErr := json.NewDecoder(r.Body).Decode(&creds)
 	if payloadErr != nil || creds.Email == "" || creds.Password == "" {
@@ -75,30 +95,30 @@ func (h *UserHandlerImpl) LoginUser(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	clientIp := utils.GetClientIP(r, config.IsLocal())
+	client := getClientInfo(r, creds.DeviceLabel)
+	client.Scope = creds.Scope
 
-	userRes, err := h.userService.Login(ctx, creds.Email, creds.Password, clientIp)
+	userRes, err := h.userService.Login(ctx, creds.Email, creds.Password, client)
 	if err != nil {
+		var blocked *services.LoginBlockedError
 		switch {
-		case errors.Is(err, domainerrors.ErrUserNotFound):
-			http.Error(w, "user not found", http.StatusNotFound)
+		case errors.As(err, &blocked):
+			writeLoginBlocked(w, blocked)
 		case errors.Is(err, domainerrors.ErrInvalidCredentials):
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrEmailNotVerified):
+			http.Error(w, "Please verify your email address first", http.StatusForbidden)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		case errors.Is(err, domainerrors.ErrInvalidScope):
+			http.Error(w, "None of the requested scopes is granted to the account", http.StatusBadRequest)
 		default:
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	saveTokenInHttpCookie(w, userRes.AccessToken, userRes.RefreshToken)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message":       "Login successful",
-		"access_token":  userRes.AccessToken,
-		"refresh_token": userRes.RefreshToken,
-		"user_id":       userRes.User.ID,
-		"email":         userRes.User.Email,
-	})
+	writeLoginResponse(w, userRes)
 }
 
 func (h *UserHandlerImpl) Profile(w http.ResponseWriter, r *http.Request) {
@@ -109,7 +129,7 @@ func (h *UserHandlerImpl) Profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.Profile(context.Background(), userContent.Claims.UserID, utils.GetClientIP(r, config.IsLocal()))
+	user, err := h.userService.Profile(context.Background(), userContent.Claims.UserID, utils.GetClientIP(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, domainerrors.ErrUserNotFound):
@@ -139,18 +159,28 @@ func (h *UserHandlerImpl) GetSilentAccesToken(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	accessToken, err := h.userService.GetSilentAccessToken(context.Background(), userContent.Claims.UserID, userContent.Claims.Email, utils.GetClientIP(r, config.IsLocal()))
-	if err != nil || accessToken == "" {
-		http.Error(w, "Could not get silent access token", http.StatusInternalServerError)
+	tokens, err := h.userService.GetSilentAccessToken(context.Background(), userContent.Claims.UserID, userContent.Claims.Email, userContent.Claims.SessionID, userContent.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrRefreshTokenReused), errors.Is(err, domainerrors.ErrSessionNotFound):
+			clearTokenInHttpCookie(w)
+			http.Error(w, "Unauthorized - invalid session", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			clearTokenInHttpCookie(w)
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		default:
+			http.Error(w, "Could not get silent access token", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	saveAccesTokenInHttpCookie(w, accessToken)
+	saveTokenInHttpCookie(w, tokens.AccessToken, tokens.RefreshToken)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message":      "Access token refreshed successful",
-		"access_token": accessToken,
+		"message":       "Access token refreshed successful",
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
@@ -162,7 +192,7 @@ func (h *UserHandlerImpl) LogoutUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := h.userService.Logout(context.Background(), userContent.Claims.UserID, userContent.AccessToken)
+	_, err := h.userService.Logout(context.Background(), userContent.Claims.UserID, userContent.Claims.SessionID, userContent.Claims.ID, userContent.Claims.ExpiresAt.Time)
 	if err != nil {
 		http.Error(w, "Logout failed", http.StatusInternalServerError)
 		return
@@ -176,7 +206,130 @@ func (h *UserHandlerImpl) LogoutUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *UserHandlerImpl) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.userService.ListSessions(r.Context(), userContent.Claims.UserID, userContent.Claims.SessionID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+func (h *UserHandlerImpl) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	sessionId := mux.Vars(r)["sessionId"]
+	_, err := h.userService.RevokeSession(r.Context(), userContent.Claims.UserID, sessionId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrSessionNotFound):
+			http.Error(w, "session not found", http.StatusNotFound)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if sessionId == userContent.Claims.SessionID {
+		clearTokenInHttpCookie(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+func (h *UserHandlerImpl) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+	if !ok {
+		http.Error(w, "Could not get user info", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := h.userService.RevokeOtherSessions(r.Context(), userContent.Claims.UserID, userContent.Claims.SessionID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
+
 // internal functions
+func getClientInfo(r *http.Request, deviceLabel string) userType.ClientInfo {
+	userAgent := r.UserAgent()
+	if deviceLabel == "" {
+		deviceLabel = userAgent
+	}
+
+	return userType.ClientInfo{
+		IPAddress:   utils.GetClientIP(r),
+		UserAgent:   userAgent,
+		DeviceLabel: deviceLabel,
+	}
+}
+
+// writeLoginResponse sets the token cookies and returns the new session, shared by every way to log in
+func writeLoginResponse(w http.ResponseWriter, userRes *userType.UserResponse) {
+	if userRes.MFAToken != "" {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Two factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    userRes.MFAToken,
+		})
+		return
+	}
+
+	saveTokenInHttpCookie(w, userRes.AccessToken, userRes.RefreshToken)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":       "Login successful",
+		"access_token":  userRes.AccessToken,
+		"refresh_token": userRes.RefreshToken,
+		"session_id":    userRes.SessionID,
+		"user_id":       userRes.User.ID,
+		"email":         userRes.User.Email,
+	})
+}
+
+func writeLoginBlocked(w http.ResponseWriter, blocked *services.LoginBlockedError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	http.Error(w, "Too many failed logins, try again later", http.StatusTooManyRequests)
+}
+
+// writeFieldErrors answers 422 with every invalid field, so a form can show all problems at once
+func writeFieldErrors(w http.ResponseWriter, fieldErrors []userType.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "validation_failed",
+		"fields": fieldErrors,
+	})
+}
+
 func saveTokenInHttpCookie(w http.ResponseWriter, accessToken string, refreshToken string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "refresh_token",
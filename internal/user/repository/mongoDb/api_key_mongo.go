@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"backend-go/constants"
+	model "backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key model.APIKey) (string, error)
+	FindByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]model.APIKey, error)
+	CountByUser(ctx context.Context, userID string) (int64, error)
+	DeleteForUser(ctx context.Context, userID string, id string) (bool, error)
+	DeleteAllForUser(ctx context.Context, userID string) (int64, error)
+	SetLastUsed(ctx context.Context, id string, lastUsedAt int64) error
+}
+
+type apiKeyRepositoryImpl struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyRepository(database *mongo.Database) APIKeyRepository {
+	return &apiKeyRepositoryImpl{
+		collection: database.Collection(constants.API_KEY_COLLECTION),
+	}
+}
+
+func (r *apiKeyRepositoryImpl) Create(ctx context.Context, key model.APIKey) (string, error) {
+	result, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	id, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return "", fmt.Errorf("unexpected api key id type %T", result.InsertedID)
+	}
+	return id.Hex(), nil
+}
+
+// FindByHash returns nil if no key has the hash
+func (r *apiKeyRepositoryImpl) FindByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepositoryImpl) ListByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []model.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepositoryImpl) CountByUser(ctx context.Context, userID string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+// DeleteForUser only deletes the key if it belongs to the user, it reports false otherwise
+func (r *apiKeyRepositoryImpl) DeleteForUser(ctx context.Context, userID string, id string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	res, collErr := r.collection.DeleteOne(ctx, bson.M{"_id": objectID, "user_id": userID})
+	if collErr != nil {
+		return false, collErr
+	}
+	return res.DeletedCount == 1, nil
+}
+
+func (r *apiKeyRepositoryImpl) DeleteAllForUser(ctx context.Context, userID string) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (r *apiKeyRepositoryImpl) SetLastUsed(ctx context.Context, id string, lastUsedAt int64) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid ID: %v", err)
+	}
+
+	_, collErr := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"last_used_at": lastUsedAt}})
+	return collErr
+}
@@ -3,9 +3,11 @@ package repository
 import (
 	"backend-go/constants"
 	model "backend-go/models"
+	userType "backend-go/type"
 	"context"
 	"fmt"
 	"log"
+	"regexp"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +21,10 @@ type UserRepository interface {
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
 	UpdateByID(ctx context.Context, id string, updatedData bson.M) (*model.User, error)
 	DeleteByID(ctx context.Context, id string) error
+	List(ctx context.Context, filter userType.UserFilter, page int, limit int) ([]model.User, int64, error)
+	SetTOTPLastStep(ctx context.Context, id string, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, id string, codeHash string) (bool, error)
+	MarkLegacyEmailsVerified(ctx context.Context) (int64, error)
 }
 
 type userRepositoryImpl struct {
@@ -103,3 +109,93 @@ func (r *userRepositoryImpl) DeleteByID(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// SetTOTPLastStep stores the time step of an accepted code only if it is newer than the last one, so a code can not be replayed
+func (r *userRepositoryImpl) SetTOTPLastStep(ctx context.Context, id string, step int64) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid ID: %v", err)
+	}
+
+	filter := bson.M{
+		"_id": objectID,
+		"$or": bson.A{
+			bson.M{"totp_last_step": bson.M{"$lt": step}},
+			bson.M{"totp_last_step": bson.M{"$exists": false}},
+		},
+	}
+	res, collErr := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if collErr != nil {
+		return false, collErr
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+// ConsumeRecoveryCode removes the code from the user, it reports false if the code was not (or no longer) there
+func (r *userRepositoryImpl) ConsumeRecoveryCode(ctx context.Context, id string, codeHash string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid ID: %v", err)
+	}
+
+	filter := bson.M{"_id": objectID, "recovery_codes": codeHash}
+	res, collErr := r.collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": codeHash}})
+	if collErr != nil {
+		return false, collErr
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+// MarkLegacyEmailsVerified sets email_verified on the accounts registered before the field existed, they are trusted like before.
+// Only accounts registered since then carry an explicit email_verified false.
+func (r *userRepositoryImpl) MarkLegacyEmailsVerified(ctx context.Context) (int64, error) {
+	filter := bson.M{"email_verified": bson.M{"$exists": false}}
+	res, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"email_verified": true}})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// List returns one page of users (page starts at 1) and the number of users matching the filter
+func (r *userRepositoryImpl) List(ctx context.Context, filter userType.UserFilter, page int, limit int) ([]model.User, int64, error) {
+	query := bson.M{}
+	if filter.Email != "" {
+		query["email"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Email), Options: "i"}
+	}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			query["disabled"] = true
+		} else {
+			query["disabled"] = bson.M{"$ne": true} // accounts created before the field existed are enabled
+		}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []model.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
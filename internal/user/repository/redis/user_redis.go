@@ -4,107 +4,215 @@ import (
 	"backend-go/constants"
 	"backend-go/database/redisx"
 	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	"backend-go/utils"
 	"context"
 	"encoding/json"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type UserRedisRepository interface {
-	StoreToken(ctx context.Context, userID string, refreshToken string, clientIp string) (interface{}, error)
-	GetToken(ctx context.Context, userID string) (*RdsTokenSession, error)
-	DeleteToken(ctx context.Context, userID string) (interface{}, error)
+	CreateSession(ctx context.Context, session rdsModel.Session) (interface{}, error)
+	GetSession(ctx context.Context, sessionID string) (*rdsModel.Session, error)
+	TouchSession(ctx context.Context, sessionID string) (interface{}, error)
+	RotateRefreshToken(ctx context.Context, sessionID string, oldRefreshToken string, newRefreshToken string) (bool, error)
+	DeleteSession(ctx context.Context, userID string, sessionID string) (interface{}, error)
+	ListSessions(ctx context.Context, userID string) ([]rdsModel.Session, error)
 	SaveUser(ctx context.Context, user model.User) (interface{}, error)
 	GetUser(ctx context.Context, userID string) (*model.User, error)
 	DeleteUser(ctx context.Context, userID string) (interface{}, error)
-	SetBlacklistOfAccessToken(ctx context.Context, userID string, accessToken string, ttlTime time.Duration) (interface{}, error)
-	IsBlacklistedAccessToken(ctx context.Context, userID string, accessToken string) (bool, error)
+	SetBlacklistOfAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) (interface{}, error)
+	IsBlacklistedAccessToken(ctx context.Context, tokenID string) (bool, error)
+	StoreOIDCLoginState(ctx context.Context, stateHash string, state rdsModel.OIDCLoginState) (interface{}, error)
+	ConsumeOIDCLoginState(ctx context.Context, stateHash string) (*rdsModel.OIDCLoginState, error)
+	IncrementMFAAttempts(ctx context.Context, tokenID string, expiresAt time.Time) (int64, error)
+	IncrementMFAEnrollmentAttempts(ctx context.Context, userID string) (int64, error)
+	MarkMFAChallengeUsed(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+	IsMFAChallengeUsed(ctx context.Context, tokenID string) (bool, error)
+	StoreOneTimeToken(ctx context.Context, purpose string, tokenHash string, token rdsModel.OneTimeToken, ttl time.Duration) (interface{}, error)
+	GetOneTimeToken(ctx context.Context, purpose string, tokenHash string) (*rdsModel.OneTimeToken, error)
+	ConsumeOneTimeToken(ctx context.Context, purpose string, tokenHash string) (*rdsModel.OneTimeToken, error)
+	RecordLoginFailure(ctx context.Context, email string) (int64, error)
+	BlockLogin(ctx context.Context, email string, until time.Time) (interface{}, error)
+	GetLoginBlock(ctx context.Context, email string) (time.Time, error)
+	ResetLoginFailures(ctx context.Context, email string) (interface{}, error)
 }
 
 type userCacheImpl struct {
 	redis *redisx.Client
 }
 
-type RdsTokenSession struct {
-	RefreshToken string `redis:"refreshToken"`
-	IPAddress    string `redis:"ipAddress"`
-}
-
 func NewUserCache(rDb *redisx.Client) UserRedisRepository {
 	return &userCacheImpl{
 		redis: rDb,
 	}
 }
 
-// methods for storing and deleting refresh tokens(used during login and logout)
-func (r *userCacheImpl) StoreToken(ctx context.Context, userID string, refreshToken string, clientIp string) (interface{}, error) {
-	key := "refreshTokenWithIp:" + userID
-	sessionData := RdsTokenSession{
-		RefreshToken: refreshToken,
-		IPAddress:    clientIp,
-	}
-	if rErr := redisx.Rdb.HSet(ctx, key, sessionData).Err(); rErr != nil {
-		log.Printf("Failed to set user session in Redis: %v", rErr)
-		return nil, rErr
-	}
+func sessionKey(sessionID string) string {
+	return constants.USER_SESSION + ":" + sessionID
+}
 
-	if rErr := redisx.Rdb.Expire(ctx, key, constants.REFRESH_TOKEN_EXPIRATION).Err(); rErr != nil {
-		log.Printf("Failed to set expiration for user session in Redis: %v", rErr)
+func sessionIndexKey(userID string) string {
+	return constants.USER_SESSION_INDEX + ":" + userID
+}
+
+// methods for storing and deleting login sessions(used during login and logout)
+func (r *userCacheImpl) CreateSession(ctx context.Context, session rdsModel.Session) (interface{}, error) {
+	key := sessionKey(session.SessionID)
+	indexKey := sessionIndexKey(session.UserID)
+
+	_, rErr := redisx.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, session)
+		pipe.Expire(ctx, key, constants.REFRESH_TOKEN_EXPIRATION)
+		pipe.SAdd(ctx, indexKey, session.SessionID)
+		pipe.Expire(ctx, indexKey, constants.REFRESH_TOKEN_EXPIRATION)
+		return nil
+	})
+	if rErr != nil {
+		log.Printf("Failed to set user session in Redis: %v", rErr)
 		return nil, rErr
 	}
 
 	return nil, nil
 }
 
-func (r *userCacheImpl) GetToken(ctx context.Context, userID string) (*RdsTokenSession, error) {
-	key := "refreshTokenWithIp:" + userID
-	session := &RdsTokenSession{}
+func (r *userCacheImpl) GetSession(ctx context.Context, sessionID string) (*rdsModel.Session, error) {
+	key := sessionKey(sessionID)
 
-	err := redisx.Rdb.HGetAll(ctx, key).Scan(session)
-	if err == redis.Nil {
-		log.Printf("Key not found in redis: %v", err)
+	res := redisx.Rdb.HGetAll(ctx, key)
+	values, err := res.Result()
+	if err != nil {
+		log.Printf("Failed to retrieve session data: %v", err)
 		return nil, err
 	}
-	if err != nil {
-		log.Printf("Failed to retrieve or scan session data: %v", err)
+	if len(values) == 0 {
 		return nil, nil // No session found
 	}
 
+	session := &rdsModel.Session{}
+	if err := res.Scan(session); err != nil {
+		log.Printf("Failed to scan session data: %v", err)
+		return nil, err
+	}
+
 	return session, nil
 }
 
-func (r *userCacheImpl) DeleteToken(ctx context.Context, userID string) (interface{}, error) {
-	key := "refreshTokenWithIp:" + userID
-	exists, err := redisx.Rdb.Exists(ctx, key).Result()
-	if err != nil {
-		log.Printf("Failed to delete user session in Redis: %v", err)
-		return nil, err
+// only touches sessions that still exist, so a revoked session is not recreated without a TTL
+var touchSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("HSET", KEYS[1], "lastSeenAt", ARGV[1])
+end
+return 0
+`)
+
+func (r *userCacheImpl) TouchSession(ctx context.Context, sessionID string) (interface{}, error) {
+	key := sessionKey(sessionID)
+	if rErr := touchSessionScript.Run(ctx, redisx.Rdb, []string{key}, time.Now().Unix()).Err(); rErr != nil {
+		log.Printf("Failed to update last seen of session in Redis: %v", rErr)
+		return nil, rErr
 	}
 
-	if exists == 0 {
-		return nil, nil // userID does not exist in Redis
+	return nil, nil
+}
+
+// swaps the refresh token of a session only if the presented token is still the current one
+var rotateRefreshTokenScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "refreshToken") == ARGV[1] then
+	redis.call("HSET", KEYS[1], "refreshToken", ARGV[2], "lastSeenAt", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+func (r *userCacheImpl) RotateRefreshToken(ctx context.Context, sessionID string, oldRefreshToken string, newRefreshToken string) (bool, error) {
+	key := sessionKey(sessionID)
+	rotated, err := rotateRefreshTokenScript.Run(ctx, redisx.Rdb, []string{key}, oldRefreshToken, newRefreshToken, time.Now().Unix()).Int()
+	if err != nil {
+		log.Printf("Failed to rotate refresh token of session in Redis: %v", err)
+		return false, err
 	}
 
-	if rErr := redisx.Rdb.Del(ctx, userID).Err(); rErr != nil {
+	return rotated == 1, nil
+}
+
+func (r *userCacheImpl) DeleteSession(ctx context.Context, userID string, sessionID string) (interface{}, error) {
+	_, rErr := redisx.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(sessionID))
+		pipe.SRem(ctx, sessionIndexKey(userID), sessionID)
+		return nil
+	})
+	if rErr != nil {
 		log.Printf("Failed to delete user session in Redis: %v", rErr)
 		return nil, rErr
 	}
-	log.Printf("User session deleted from Redis: %s", userID)
+	log.Printf("User session %s deleted from Redis: %s", sessionID, userID)
 
 	return nil, nil
 }
 
+func (r *userCacheImpl) ListSessions(ctx context.Context, userID string) ([]rdsModel.Session, error) {
+	indexKey := sessionIndexKey(userID)
+	sessionIDs, err := redisx.Rdb.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		log.Printf("Failed to list user sessions in Redis: %v", err)
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(sessionIDs))
+	_, err = redisx.Rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, sessionID := range sessionIDs {
+			cmds[i] = pipe.HGetAll(ctx, sessionKey(sessionID))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to retrieve user sessions in Redis: %v", err)
+		return nil, err
+	}
+
+	sessions := make([]rdsModel.Session, 0, len(sessionIDs))
+	var expired []interface{}
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			// session hash already expired, only the index entry is left
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
+
+		var session rdsModel.Session
+		if err := cmd.Scan(&session); err != nil {
+			log.Printf("Failed to scan session data: %v", err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		if rErr := redisx.Rdb.SRem(ctx, indexKey, expired...).Err(); rErr != nil {
+			log.Printf("Failed to prune expired sessions in Redis: %v", rErr)
+		}
+	}
+
+	return sessions, nil
+}
+
 // method to store user profile
 func (r *userCacheImpl) SaveUser(ctx context.Context, user model.User) (interface{}, error) {
 	key := "userProfile:" + user.ID
 	userData := model.User{
-		ID:        user.ID,
-		Email:     user.Email,
-		Role:      user.Role,
-		Token:     user.Token,
-		IPAddress: user.IPAddress,
+		ID:            user.ID,
+		Email:         user.Email,
+		Role:          user.Role,
+		Token:         user.Token,
+		IPAddress:     user.IPAddress,
+		MFAEnabled:    user.MFAEnabled,
+		EmailVerified: user.EmailVerified,
+		Disabled:      user.Disabled,
 	}
 
 	userStringfy, jErr := json.Marshal(userData)
@@ -163,16 +271,17 @@ func (r *userCacheImpl) DeleteUser(ctx context.Context, userID string) (interfac
 	return nil, nil
 }
 
-// methods for blacklisting access tokens (used during logout)
-func (r *userCacheImpl) SetBlacklistOfAccessToken(ctx context.Context, userID string, accessTokentoken string, ttlTime time.Duration) (interface{}, error) {
-	key := constants.BLACKLIST_ACCESS_TOKEN + ":" + userID
+// methods for blacklisting access tokens by their jti (used during logout)
+// every entry lives exactly as long as the token itself, so several revoked tokens of a user can coexist
+func (r *userCacheImpl) SetBlacklistOfAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) (interface{}, error) {
+	key := constants.BLACKLIST_ACCESS_TOKEN + ":" + tokenID
 
-	exists, _ := redisx.Rdb.Exists(ctx, key).Result()
-	if exists > 0 {
-		return nil, nil // Token is already blacklisted
+	ttlTime := time.Until(expiresAt)
+	if ttlTime <= 0 {
+		return nil, nil // Token is already expired
 	}
 
-	if rErr := redisx.Rdb.Set(ctx, key, accessTokentoken, ttlTime).Err(); rErr != nil {
+	if rErr := redisx.Rdb.Set(ctx, key, 1, ttlTime).Err(); rErr != nil {
 		log.Printf("Failed to set blacklisted access token in Redis: %v", rErr)
 		return nil, rErr
 	}
@@ -180,16 +289,232 @@ func (r *userCacheImpl) SetBlacklistOfAccessToken(ctx context.Context, userID st
 	return nil, nil
 }
 
-func (r *userCacheImpl) IsBlacklistedAccessToken(ctx context.Context, userId string, accessTokentoken string) (bool, error) {
-	key := constants.BLACKLIST_ACCESS_TOKEN + ":" + userId
-	res, err := redisx.Rdb.Get(ctx, key).Result()
-	if redis.Nil == err {
-		return false, nil
-	}
+func (r *userCacheImpl) IsBlacklistedAccessToken(ctx context.Context, tokenID string) (bool, error) {
+	key := constants.BLACKLIST_ACCESS_TOKEN + ":" + tokenID
+	exists, err := redisx.Rdb.Exists(ctx, key).Result()
 	if err != nil {
 		log.Printf("Failed to check if access token is blacklisted in Redis: %v", err)
 		return false, err
 	}
 
-	return res == accessTokentoken, nil
+	return exists > 0, nil
+}
+
+// methods for pending logins through external OIDC providers, only the hash of the state is used as key
+func (r *userCacheImpl) StoreOIDCLoginState(ctx context.Context, stateHash string, state rdsModel.OIDCLoginState) (interface{}, error) {
+	key := constants.OIDC_LOGIN_STATE + ":" + stateHash
+
+	stateStringfy, jErr := json.Marshal(state)
+	if jErr != nil {
+		log.Printf("Error marshalling oidc login state: %v", jErr)
+		return nil, jErr
+	}
+
+	if rErr := redisx.Rdb.Set(ctx, key, stateStringfy, constants.OIDC_LOGIN_STATE_EXPIRATION).Err(); rErr != nil {
+		log.Printf("Failed to set oidc login state in Redis: %v", rErr)
+		return nil, rErr
+	}
+
+	return nil, nil
+}
+
+// ConsumeOIDCLoginState reads and deletes the state in one step so a callback can only be completed once
+func (r *userCacheImpl) ConsumeOIDCLoginState(ctx context.Context, stateHash string) (*rdsModel.OIDCLoginState, error) {
+	key := constants.OIDC_LOGIN_STATE + ":" + stateHash
+
+	res, err := redisx.Rdb.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to consume oidc login state in Redis: %v", err)
+		return nil, err
+	}
+
+	var state rdsModel.OIDCLoginState
+	if jErr := json.Unmarshal([]byte(res), &state); jErr != nil {
+		log.Printf("Error unmarshalling oidc login state: %v", jErr)
+		return nil, jErr
+	}
+
+	return &state, nil
+}
+
+// IncrementMFAAttempts counts the codes tried with one MFA challenge token, the counter expires with the token
+func (r *userCacheImpl) IncrementMFAAttempts(ctx context.Context, tokenID string, expiresAt time.Time) (int64, error) {
+	key := constants.MFA_CHALLENGE_ATTEMPTS + ":" + tokenID
+
+	var incr *redis.IntCmd
+	_, rErr := redisx.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.ExpireAt(ctx, key, expiresAt)
+		return nil
+	})
+	if rErr != nil {
+		log.Printf("Failed to count mfa attempts in Redis: %v", rErr)
+		return 0, rErr
+	}
+
+	return incr.Val(), nil
+}
+
+// IncrementMFAEnrollmentAttempts counts the codes tried to confirm a pending enrollment, the window restarts with every attempt
+func (r *userCacheImpl) IncrementMFAEnrollmentAttempts(ctx context.Context, userID string) (int64, error) {
+	key := constants.MFA_ENROLLMENT_ATTEMPTS + ":" + userID
+
+	var incr *redis.IntCmd
+	_, rErr := redisx.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, constants.MFA_ENROLLMENT_ATTEMPTS_WINDOW)
+		return nil
+	})
+	if rErr != nil {
+		log.Printf("Failed to count mfa enrollment attempts in Redis: %v", rErr)
+		return 0, rErr
+	}
+
+	return incr.Val(), nil
+}
+
+// MarkMFAChallengeUsed redeems a challenge token, it reports false when the token was already redeemed
+func (r *userCacheImpl) MarkMFAChallengeUsed(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+
+	fresh, rErr := redisx.Rdb.SetNX(ctx, constants.MFA_CHALLENGE_USED+":"+tokenID, true, ttl).Result()
+	if rErr != nil {
+		log.Printf("Failed to mark mfa challenge as used in Redis: %v", rErr)
+		return false, rErr
+	}
+
+	return fresh, nil
+}
+
+func (r *userCacheImpl) IsMFAChallengeUsed(ctx context.Context, tokenID string) (bool, error) {
+	count, rErr := redisx.Rdb.Exists(ctx, constants.MFA_CHALLENGE_USED+":"+tokenID).Result()
+	if rErr != nil {
+		log.Printf("Failed to check mfa challenge in Redis: %v", rErr)
+		return false, rErr
+	}
+
+	return count > 0, nil
+}
+
+// methods for emailed single use tokens, the purpose keeps e.g. a reset token from being redeemed as a login link
+func oneTimeTokenKey(purpose string, tokenHash string) string {
+	return constants.ONE_TIME_TOKEN + ":" + purpose + ":" + tokenHash
+}
+
+func (r *userCacheImpl) StoreOneTimeToken(ctx context.Context, purpose string, tokenHash string, token rdsModel.OneTimeToken, ttl time.Duration) (interface{}, error) {
+	tokenStringfy, jErr := json.Marshal(token)
+	if jErr != nil {
+		log.Printf("Error marshalling one time token: %v", jErr)
+		return nil, jErr
+	}
+
+	if rErr := redisx.Rdb.Set(ctx, oneTimeTokenKey(purpose, tokenHash), tokenStringfy, ttl).Err(); rErr != nil {
+		log.Printf("Failed to set one time token in Redis: %v", rErr)
+		return nil, rErr
+	}
+
+	return nil, nil
+}
+
+// GetOneTimeToken reads the token without redeeming it
+func (r *userCacheImpl) GetOneTimeToken(ctx context.Context, purpose string, tokenHash string) (*rdsModel.OneTimeToken, error) {
+	res, err := redisx.Rdb.Get(ctx, oneTimeTokenKey(purpose, tokenHash)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to get one time token from Redis: %v", err)
+		return nil, err
+	}
+
+	return unmarshalOneTimeToken(res)
+}
+
+// ConsumeOneTimeToken reads and deletes the token in one step so it can only be redeemed once
+func (r *userCacheImpl) ConsumeOneTimeToken(ctx context.Context, purpose string, tokenHash string) (*rdsModel.OneTimeToken, error) {
+	res, err := redisx.Rdb.GetDel(ctx, oneTimeTokenKey(purpose, tokenHash)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to consume one time token in Redis: %v", err)
+		return nil, err
+	}
+
+	return unmarshalOneTimeToken(res)
+}
+
+func unmarshalOneTimeToken(res string) (*rdsModel.OneTimeToken, error) {
+	var token rdsModel.OneTimeToken
+	if jErr := json.Unmarshal([]byte(res), &token); jErr != nil {
+		log.Printf("Error unmarshalling one time token: %v", jErr)
+		return nil, jErr
+	}
+
+	return &token, nil
+}
+
+// methods for throttling failed password logins per account. The email is hashed so the keys
+// neither leak addresses nor depend on the account existing.
+func loginAttemptsKey(prefix string, email string) string {
+	return prefix + ":" + utils.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+func (r *userCacheImpl) RecordLoginFailure(ctx context.Context, email string) (int64, error) {
+	key := loginAttemptsKey(constants.LOGIN_FAILURES, email)
+
+	var incr *redis.IntCmd
+	_, rErr := redisx.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, constants.LOGIN_FAILURE_WINDOW)
+		return nil
+	})
+	if rErr != nil {
+		log.Printf("Failed to count login failures in Redis: %v", rErr)
+		return 0, rErr
+	}
+
+	return incr.Val(), nil
+}
+
+func (r *userCacheImpl) BlockLogin(ctx context.Context, email string, until time.Time) (interface{}, error) {
+	key := loginAttemptsKey(constants.LOGIN_BLOCKED_UNTIL, email)
+
+	res, err := redisx.Rdb.Set(ctx, key, until.Unix(), time.Until(until)).Result()
+	if err != nil {
+		log.Printf("Failed to block login in Redis: %v", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetLoginBlock returns the time until which logins are refused, the zero time if they are not
+func (r *userCacheImpl) GetLoginBlock(ctx context.Context, email string) (time.Time, error) {
+	res, err := redisx.Rdb.Get(ctx, loginAttemptsKey(constants.LOGIN_BLOCKED_UNTIL, email)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		log.Printf("Failed to read login block from Redis: %v", err)
+		return time.Time{}, err
+	}
+
+	return time.Unix(res, 0), nil
+}
+
+func (r *userCacheImpl) ResetLoginFailures(ctx context.Context, email string) (interface{}, error) {
+	res, err := redisx.Rdb.Del(ctx, loginAttemptsKey(constants.LOGIN_FAILURES, email), loginAttemptsKey(constants.LOGIN_BLOCKED_UNTIL, email)).Result()
+	if err != nil {
+		log.Printf("Failed to reset login failures in Redis: %v", err)
+		return nil, err
+	}
+
+	return res, nil
 }
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	repository "backend-go/internal/user/repository/mongoDb"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// APIKeyService manages the personal API keys of users, AuthMiddleware accepts them through TokenValidator.ValidateAPIKey
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userId string, callerScopes []string, req userType.APIKeyRequest) (*userType.CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context, userId string) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userId string, keyId string) error
+}
+
+type APIKeyServiceImpl struct {
+	apiKeyRepo repository.APIKeyRepository
+	repo       repository.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, r repository.UserRepository) *APIKeyServiceImpl {
+	return &APIKeyServiceImpl{
+		apiKeyRepo: apiKeyRepo,
+		repo:       r,
+	}
+}
+
+// CreateAPIKey returns the key once, only its hash is stored. The scopes must be permissions of the user's role and
+// scopes of the token creating the key, a key can never do more than its owner or the session it was made from.
+// Managing the account is left to login sessions.
+func (s *APIKeyServiceImpl) CreateAPIKey(ctx context.Context, userId string, callerScopes []string, req userType.APIKeyRequest) (*userType.CreatedAPIKey, error) {
+	user, err := s.repo.FindByID(ctx, userId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domainerrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > constants.API_KEY_NAME_MAX_LENGTH {
+		return nil, domainerrors.ErrInvalidAPIKeyName
+	}
+
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || slices.Contains(scopes, scope) {
+			continue
+		}
+		if scope == "*" || scope == constants.PERMISSION_ACCOUNT_MANAGE || !utils.HasPermission(roleOf(user), scope) || !utils.HasScope(callerScopes, scope) {
+			return nil, domainerrors.ErrInvalidScope
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, domainerrors.ErrInvalidScope
+	}
+
+	// the days are capped before they are turned into a duration, a large value would overflow it
+	if req.ExpiresInDays < 0 {
+		return nil, domainerrors.ErrInvalidAPIKeyExpiry
+	}
+	expiresIn := constants.API_KEY_DEFAULT_EXPIRATION
+	if req.ExpiresInDays > 0 {
+		days := min(req.ExpiresInDays, int(constants.API_KEY_MAX_EXPIRATION/(24*time.Hour)))
+		expiresIn = time.Duration(days) * 24 * time.Hour
+	}
+
+	count, err := s.apiKeyRepo.CountByUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= constants.API_KEY_MAX_PER_USER {
+		return nil, domainerrors.ErrTooManyAPIKeys
+	}
+
+	secret, err := utils.GenerateSecureToken(constants.API_KEY_BYTES)
+	if err != nil {
+		log.Printf("apiKeyService.CreateAPIKey: %v", err)
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
+	rawKey := constants.API_KEY_PREFIX + secret
+
+	now := time.Now()
+	key := model.APIKey{
+		UserID:    userId,
+		Name:      name,
+		Prefix:    rawKey[:constants.API_KEY_DISPLAY_LENGTH],
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    scopes,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(expiresIn).Unix(),
+	}
+	id, err := s.apiKeyRepo.Create(ctx, key)
+	if err != nil {
+		log.Printf("apiKeyService.CreateAPIKey: %v", err)
+		return nil, err
+	}
+	key.ID = id
+	utils.LogSecurityEvent("api_key_created", "user %s created api key %s with scopes %v", userId, id, scopes)
+
+	return &userType.CreatedAPIKey{Key: rawKey, APIKey: &key}, nil
+}
+
+func (s *APIKeyServiceImpl) ListAPIKeys(ctx context.Context, userId string) ([]model.APIKey, error) {
+	return s.apiKeyRepo.ListByUser(ctx, userId)
+}
+
+func (s *APIKeyServiceImpl) RevokeAPIKey(ctx context.Context, userId string, keyId string) error {
+	deleted, err := s.apiKeyRepo.DeleteForUser(ctx, userId, keyId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domainerrors.ErrAPIKeyNotFound
+	}
+	utils.LogSecurityEvent("api_key_revoked", "user %s revoked api key %s", userId, keyId)
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package services_test
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	repository "backend-go/internal/user/repository/mongoDb"
+	redisRepository "backend-go/internal/user/repository/redis"
+	"backend-go/internal/user/services"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeAPIKeys keeps the keys in memory by their hash, like the unique index of the collection
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+	keys map[string]model.APIKey
+}
+
+func (f *fakeAPIKeys) Create(ctx context.Context, key model.APIKey) (string, error) {
+	key.ID = fmt.Sprintf("key%d", len(f.keys)+1)
+	f.keys[key.KeyHash] = key
+	return key.ID, nil
+}
+
+func (f *fakeAPIKeys) FindByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	key, ok := f.keys[keyHash]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (f *fakeAPIKeys) CountByUser(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	for _, key := range f.keys {
+		if key.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeAPIKeys) SetLastUsed(ctx context.Context, id string, lastUsedAt int64) error {
+	return nil
+}
+
+type fakeUsers struct {
+	repository.UserRepository
+	users map[string]model.User
+}
+
+func (f *fakeUsers) FindByID(ctx context.Context, id string) (*model.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &user, nil
+}
+
+// fakeUserRedis never has a cached profile, so every lookup reads the user repository
+type fakeUserRedis struct {
+	redisRepository.UserRedisRepository
+}
+
+func (f *fakeUserRedis) GetUser(ctx context.Context, userID string) (*model.User, error) {
+	return nil, nil
+}
+
+func (f *fakeUserRedis) SaveUser(ctx context.Context, user model.User) (interface{}, error) {
+	return nil, nil
+}
+
+// loginScopes are the scopes of a login that did not ask for any
+var loginScopes = utils.GrantScopes(constants.ROLE_USER, nil)
+
+type apiKeyFixture struct {
+	service   *services.APIKeyServiceImpl
+	validator *services.TokenValidatorImpl
+	apiKeys   *fakeAPIKeys
+	users     *fakeUsers
+}
+
+func newAPIKeyFixture() *apiKeyFixture {
+	f := &apiKeyFixture{
+		apiKeys: &fakeAPIKeys{keys: map[string]model.APIKey{}},
+		users: &fakeUsers{users: map[string]model.User{
+			"user123": {ID: "user123", Email: "test@gmail.com", Role: constants.ROLE_USER},
+		}},
+	}
+	f.service = services.NewAPIKeyService(f.apiKeys, f.users)
+	f.validator = services.NewTokenValidator(&fakeUserRedis{}, f.apiKeys, f.users)
+	return f
+}
+
+func (f *apiKeyFixture) create(t *testing.T, req userType.APIKeyRequest) *userType.CreatedAPIKey {
+	created, err := f.service.CreateAPIKey(context.Background(), "user123", loginScopes, req)
+	require.NoError(t, err)
+	return created
+}
+
+func TestCreateAPIKey_StoresOnlyTheHash(t *testing.T) {
+	f := newAPIKeyFixture()
+
+	created := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}})
+	assert.True(t, strings.HasPrefix(created.Key, constants.API_KEY_PREFIX))
+	assert.Equal(t, created.Key[:constants.API_KEY_DISPLAY_LENGTH], created.APIKey.Prefix)
+
+	stored, ok := f.apiKeys.keys[utils.HashToken(created.Key)]
+	require.True(t, ok, "the key is found by its sha256")
+	assert.NotContains(t, stored.KeyHash, created.Key)
+	assert.Equal(t, "user123", stored.UserID)
+}
+
+func TestCreateAPIKey_Scopes(t *testing.T) {
+	f := newAPIKeyFixture()
+
+	created := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: []string{" profile:read ", "profile:read", ""}})
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, created.APIKey.Scopes, "scopes are trimmed and deduplicated")
+
+	invalid := [][]string{
+		nil,
+		{""},
+		{"*"},
+		{constants.PERMISSION_USERS_WRITE},    // not a permission of the user role
+		{constants.PERMISSION_ACCOUNT_MANAGE}, // left to login sessions
+		{constants.PERMISSION_PROFILE_READ, "unknown:scope"},
+	}
+	for _, scopes := range invalid {
+		_, err := f.service.CreateAPIKey(context.Background(), "user123", loginScopes, userType.APIKeyRequest{Name: "ci", Scopes: scopes})
+		assert.ErrorIs(t, err, domainerrors.ErrInvalidScope, "scopes %v", scopes)
+	}
+}
+
+func TestCreateAPIKey_CappedByCallerScopes(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := context.Background()
+	callerScopes := []string{constants.PERMISSION_PROFILE_READ, constants.PERMISSION_ACCOUNT_MANAGE}
+
+	_, err := f.service.CreateAPIKey(ctx, "user123", callerScopes, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_WRITE}})
+	assert.ErrorIs(t, err, domainerrors.ErrInvalidScope, "the role grants profile:write, the login that creates the key does not")
+
+	created, err := f.service.CreateAPIKey(ctx, "user123", callerScopes, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, created.APIKey.Scopes)
+}
+
+func TestCreateAPIKey_Expiry(t *testing.T) {
+	f := newAPIKeyFixture()
+	scopes := []string{constants.PERMISSION_PROFILE_READ}
+
+	tests := []struct {
+		days int
+		want time.Duration
+	}{
+		{0, constants.API_KEY_DEFAULT_EXPIRATION},
+		{7, 7 * 24 * time.Hour},
+		{5000, constants.API_KEY_MAX_EXPIRATION},
+		{math.MaxInt, constants.API_KEY_MAX_EXPIRATION}, // would overflow the duration
+	}
+	for _, tt := range tests {
+		key := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: scopes, ExpiresInDays: tt.days}).APIKey
+		assert.Equal(t, int64(tt.want.Seconds()), key.ExpiresAt-key.CreatedAt, "expires_in_days=%d", tt.days)
+	}
+
+	_, err := f.service.CreateAPIKey(context.Background(), "user123", loginScopes, userType.APIKeyRequest{Name: "ci", Scopes: scopes, ExpiresInDays: -5})
+	assert.ErrorIs(t, err, domainerrors.ErrInvalidAPIKeyExpiry)
+}
+
+func TestCreateAPIKey_Name(t *testing.T) {
+	f := newAPIKeyFixture()
+	scopes := []string{constants.PERMISSION_PROFILE_READ}
+
+	created := f.create(t, userType.APIKeyRequest{Name: "  deploy  ", Scopes: scopes})
+	assert.Equal(t, "deploy", created.APIKey.Name)
+
+	for _, name := range []string{"", "   ", strings.Repeat("x", constants.API_KEY_NAME_MAX_LENGTH+1)} {
+		_, err := f.service.CreateAPIKey(context.Background(), "user123", loginScopes, userType.APIKeyRequest{Name: name, Scopes: scopes})
+		assert.ErrorIs(t, err, domainerrors.ErrInvalidAPIKeyName, "name %q", name)
+	}
+	assert.Len(t, f.apiKeys.keys, 1, "invalid names create no key")
+}
+
+func TestCreateAPIKey_LimitPerUser(t *testing.T) {
+	f := newAPIKeyFixture()
+	req := userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}}
+
+	for i := 0; i < constants.API_KEY_MAX_PER_USER; i++ {
+		f.create(t, req)
+	}
+	_, err := f.service.CreateAPIKey(context.Background(), "user123", loginScopes, req)
+	assert.ErrorIs(t, err, domainerrors.ErrTooManyAPIKeys)
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := context.Background()
+	created := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}})
+
+	claims, key, err := f.validator.ValidateAPIKey(ctx, created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, created.APIKey.ID, key.ID)
+	assert.Equal(t, "user123", claims.UserID)
+	assert.Equal(t, utils.APIKeyTokenType, claims.TokenType)
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, claims.Scopes())
+
+	for _, rawKey := range []string{"", "not-a-key", strings.TrimPrefix(created.Key, constants.API_KEY_PREFIX), created.Key + "x"} {
+		_, _, err := f.validator.ValidateAPIKey(ctx, rawKey)
+		assert.ErrorIs(t, err, domainerrors.ErrInvalidToken, "key %q", rawKey)
+	}
+}
+
+func TestValidateAPIKey_Expired(t *testing.T) {
+	f := newAPIKeyFixture()
+	created := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}})
+
+	hash := utils.HashToken(created.Key)
+	key := f.apiKeys.keys[hash]
+	key.ExpiresAt = time.Now().Unix()
+	f.apiKeys.keys[hash] = key
+
+	_, _, err := f.validator.ValidateAPIKey(context.Background(), created.Key)
+	assert.ErrorIs(t, err, domainerrors.ErrInvalidToken)
+}
+
+func TestValidateAPIKey_DisabledUser(t *testing.T) {
+	f := newAPIKeyFixture()
+	created := f.create(t, userType.APIKeyRequest{Name: "ci", Scopes: []string{constants.PERMISSION_PROFILE_READ}})
+
+	user := f.users.users["user123"]
+	user.Disabled = true
+	f.users.users["user123"] = user
+
+	_, _, err := f.validator.ValidateAPIKey(context.Background(), created.Key)
+	assert.ErrorIs(t, err, domainerrors.ErrAccountDisabled)
+
+	delete(f.users.users, "user123")
+	_, _, err = f.validator.ValidateAPIKey(context.Background(), created.Key)
+	assert.ErrorIs(t, err, domainerrors.ErrInvalidToken, "keys of deleted users stop working")
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/mailer"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ChangePassword needs the current password, wrong guesses count towards the account's login lockout.
+// Every session except the current one is signed out.
+func (s *UserServiceImpl) ChangePassword(ctx context.Context, userId string, sessionId string, currentPassword string, newPassword string) (int, error) {
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.verifyCurrentPassword(ctx, user.Email, user.Password, currentPassword); err != nil {
+		return 0, err
+	}
+	if err := s.checkPassword(newPassword, user.Email); err != nil {
+		return 0, err
+	}
+
+	hashed, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return 0, domainerrors.ErrSomethingWentWrong
+	}
+	if _, err := s.updateUserByID(ctx, user.ID, bson.M{"password": hashed}); err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.RevokeOtherSessions(ctx, user.ID, sessionId)
+	if err != nil {
+		return 0, err
+	}
+	utils.LogSecurityEvent("password_changed", "user %s changed the password, %d other sessions revoked", user.ID, revoked)
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body:    "The password of your account was just changed and your other devices were signed out.\n\nIf it was not you, reset your password right away.",
+	})
+
+	return revoked, nil
+}
+
+// RequestEmailChange emails a confirmation link to the new address, the account keeps its current email until the link is opened.
+// Accounts without a password (external or magic link logins) can not re-authenticate here, a session alone must not be enough
+// to take over the account, so the current address has to approve the change first (see ApproveEmailChange).
+func (s *UserServiceImpl) RequestEmailChange(ctx context.Context, userId string, password string, newEmail string, client userType.ClientInfo) error {
+	newEmail = strings.TrimSpace(newEmail)
+
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Password != "" {
+		if err := s.verifyCurrentPassword(ctx, user.Email, user.Password, password); err != nil {
+			return err
+		}
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return domainerrors.ErrEmailTaken
+	}
+	if err := s.ensureEmailAvailable(ctx, user.ID, newEmail); err != nil {
+		return err
+	}
+
+	if user.Password == "" {
+		return s.sendEmailChangeApproval(ctx, user, newEmail, client)
+	}
+	return s.sendEmailChangeConfirmation(ctx, user, newEmail, client)
+}
+
+// ApproveEmailChange redeems the link sent to the current address of an account without a password
+// and goes on like RequestEmailChange, with a confirmation link to the new address.
+func (s *UserServiceImpl) ApproveEmailChange(ctx context.Context, token string) error {
+	approval, err := s.redisRepo.ConsumeOneTimeToken(ctx, constants.EMAIL_CHANGE_APPROVAL_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if approval == nil {
+		return domainerrors.ErrInvalidToken
+	}
+
+	user, err := s.findUser(ctx, approval.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureEmailAvailable(ctx, user.ID, approval.Email); err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("email_change_approved", "user %s approved the change from the current address", user.ID)
+
+	client := userType.ClientInfo{IPAddress: approval.IPAddress, UserAgent: approval.UserAgent}
+	return s.sendEmailChangeConfirmation(ctx, user, approval.Email, client)
+}
+
+// sendEmailChangeApproval asks the current address to approve the change, the token carries the new address
+func (s *UserServiceImpl) sendEmailChangeApproval(ctx context.Context, user *model.User, newEmail string, client userType.ClientInfo) error {
+	target := *user
+	target.Email = newEmail
+	token, err := s.issueOneTimeToken(ctx, constants.EMAIL_CHANGE_APPROVAL_PURPOSE, &target, client, constants.EMAIL_CHANGE_APPROVAL_EXPIRATION)
+	if err != nil {
+		return err
+	}
+
+	link := constants.EMAIL_CHANGE_APPROVAL_URL + "?token=" + url.QueryEscape(token)
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Approve the change of your email address",
+		Body: fmt.Sprintf("Someone asked to change the email address of your account to %s.\n\nOpen this link within %d minutes to approve it:\n%s\n\nIf it was not you, ignore this email and sign out your other devices.",
+			newEmail, int(constants.EMAIL_CHANGE_APPROVAL_EXPIRATION.Minutes()), link),
+	})
+
+	return nil
+}
+
+// sendEmailChangeConfirmation mails the link that switches the account to the new address
+func (s *UserServiceImpl) sendEmailChangeConfirmation(ctx context.Context, user *model.User, newEmail string, client userType.ClientInfo) error {
+	// the token is issued for the new address, ConfirmEmailChange takes it from there
+	target := *user
+	target.Email = newEmail
+	token, err := s.issueOneTimeToken(ctx, constants.EMAIL_CHANGE_PURPOSE, &target, client, constants.EMAIL_CHANGE_EXPIRATION)
+	if err != nil {
+		return err
+	}
+
+	link := constants.EMAIL_CHANGE_URL + "?token=" + url.QueryEscape(token)
+	s.sendMail(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Please confirm that this is the new email address of your account by opening this link within %d hours:\n%s\n\nIf you did not ask for this, ignore this email.",
+			int(constants.EMAIL_CHANGE_EXPIRATION.Hours()), link),
+	})
+
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the new address, which is verified by the link itself.
+// Every session is signed out, their tokens still carry the old address.
+func (s *UserServiceImpl) ConfirmEmailChange(ctx context.Context, token string) error {
+	change, err := s.redisRepo.ConsumeOneTimeToken(ctx, constants.EMAIL_CHANGE_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return domainerrors.ErrInvalidToken
+	}
+
+	user, err := s.findUser(ctx, change.UserID)
+	if err != nil {
+		return err
+	}
+	// someone may have registered the address since the link was sent
+	if err := s.ensureEmailAvailable(ctx, user.ID, change.Email); err != nil {
+		return err
+	}
+
+	if _, err := s.updateUserByID(ctx, user.ID, bson.M{"email": change.Email, "email_verified": true}); err != nil {
+		return err
+	}
+	// failed logins were counted for the old address
+	s.resetLoginFailures(ctx, user.Email)
+
+	revoked, err := s.RevokeOtherSessions(ctx, user.ID, "")
+	if err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("email_changed", "user %s changed the email address, %d sessions revoked", user.ID, revoked)
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf("The email address of your account was changed to %s and your devices were signed out.\n\nIf it was not you, contact support right away.", change.Email),
+	})
+
+	return nil
+}
+
+// verifyCurrentPassword applies the login lockout to re-authentication, a stolen session must not allow guessing the password
+func (s *UserServiceImpl) verifyCurrentPassword(ctx context.Context, email string, hash string, password string) error {
+	if err := s.checkLoginBlock(ctx, email); err != nil {
+		return err
+	}
+
+	if ok, _ := s.hasher.Verify(password, hash); !ok {
+		s.recordLoginFailure(ctx, email)
+		return domainerrors.ErrInvalidCredentials
+	}
+	s.resetLoginFailures(ctx, email)
+
+	return nil
+}
+
+func (s *UserServiceImpl) ensureEmailAvailable(ctx context.Context, userId string, email string) error {
+	existing, err := s.repo.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if existing != nil && existing.ID != userId {
+		return domainerrors.ErrEmailTaken
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/mailer"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ResendVerificationEmail sends a new link to unverified accounts, like RequestPasswordReset it never tells whether the email is known
+func (s *UserServiceImpl) ResendVerificationEmail(ctx context.Context, email string, client userType.ClientInfo) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user, client)
+}
+
+// VerifyEmail redeems the emailed token. The token is only valid for the address it was sent to.
+func (s *UserServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	verification, err := s.redisRepo.ConsumeOneTimeToken(ctx, constants.EMAIL_VERIFICATION_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if verification == nil {
+		return domainerrors.ErrInvalidToken
+	}
+
+	user, err := s.findUser(ctx, verification.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email != verification.Email {
+		return domainerrors.ErrInvalidToken
+	}
+
+	if _, err := s.updateUserByID(ctx, user.ID, bson.M{"email_verified": true}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserServiceImpl) sendVerificationEmail(ctx context.Context, user *model.User, client userType.ClientInfo) error {
+	token, err := s.issueOneTimeToken(ctx, constants.EMAIL_VERIFICATION_PURPOSE, user, client, constants.EMAIL_VERIFICATION_EXPIRATION)
+	if err != nil {
+		return err
+	}
+
+	link := constants.EMAIL_VERIFICATION_URL + "?token=" + url.QueryEscape(token)
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Please confirm that this is your email address by opening this link within %d hours:\n%s",
+			int(constants.EMAIL_VERIFICATION_EXPIRATION.Hours()), link),
+	})
+
+	return nil
+}
+
+// markEmailVerified is called once a login proved control of the address (a provider's email_verified claim or a magic link).
+// An unverified account with that email may have been registered by someone else to take over the real owner's login,
+// so its password and sessions are discarded and the owner is told why.
+func (s *UserServiceImpl) markEmailVerified(ctx context.Context, user *model.User, verifiedBy string) error {
+	if user.EmailVerified {
+		return nil
+	}
+	if user.Password == "" {
+		// nobody could log in to it with a password, there is nothing to discard
+		if _, err := s.updateUserByID(ctx, user.ID, bson.M{"email_verified": true}); err != nil {
+			return err
+		}
+		user.EmailVerified = true
+		return nil
+	}
+
+	if _, err := s.updateUserByID(ctx, user.ID, bson.M{"email_verified": true, "password": "", "token": ""}); err != nil {
+		return err
+	}
+	if _, err := s.RevokeOtherSessions(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("unverified_account_claimed", "user %s verified through %s, password and sessions discarded", user.ID, verifiedBy)
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was removed",
+		Body: fmt.Sprintf("Your email address was just confirmed by a login through %s.\n\n"+
+			"The account had been registered with this address before it was verified, possibly by someone else, "+
+			"so its password was removed and all devices were signed out.\n\n"+
+			"If you registered the account yourself, choose a new password with the password reset.", verifiedBy),
+	})
+
+	user.EmailVerified = true
+	user.Password = ""
+	return nil
+}
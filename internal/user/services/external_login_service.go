@@ -0,0 +1,115 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StartExternalLogin begins the authorization code flow with an upstream provider and returns where to send the user.
+// The state is returned as well, the handler binds it to the browser.
+func (s *UserServiceImpl) StartExternalLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", domainerrors.ErrUnknownProvider
+	}
+
+	state, errState := utils.GenerateSecureToken(constants.OIDC_LOGIN_STATE_BYTES)
+	nonce, errNonce := utils.GenerateSecureToken(constants.OIDC_LOGIN_STATE_BYTES)
+	codeVerifier, errVerifier := utils.GenerateSecureToken(constants.OIDC_LOGIN_STATE_BYTES)
+	if errState != nil || errNonce != nil || errVerifier != nil {
+		log.Printf("userService.StartExternalLogin: error generating state: %v %v %v", errState, errNonce, errVerifier)
+		return "", "", domainerrors.ErrSomethingWentWrong
+	}
+
+	loginState := rdsModel.OIDCLoginState{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}
+	if _, err := s.redisRepo.StoreOIDCLoginState(ctx, utils.HashToken(state), loginState); err != nil {
+		return "", "", domainerrors.ErrStoringTokenInRedis
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, utils.PKCEChallenge(codeVerifier))
+	if err != nil {
+		log.Printf("userService.StartExternalLogin: %v", err)
+		return "", "", domainerrors.ErrExternalLoginFailed
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteExternalLogin handles the provider's callback. The user is found or created by the verified email of the ID token
+// and signed in like a password login, including the second factor.
+func (s *UserServiceImpl) CompleteExternalLogin(ctx context.Context, providerName string, code string, state string, client userType.ClientInfo) (*userType.UserResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, domainerrors.ErrUnknownProvider
+	}
+
+	loginState, err := s.redisRepo.ConsumeOIDCLoginState(ctx, utils.HashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if loginState == nil || loginState.Provider != providerName {
+		return nil, domainerrors.ErrExternalLoginFailed
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("userService.CompleteExternalLogin: code exchange with %s failed: %v", providerName, err)
+		return nil, domainerrors.ErrExternalLoginFailed
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		utils.LogSecurityEvent("external_id_token_rejected", "provider=%s ip=%s: %v", providerName, client.IPAddress, err)
+		return nil, domainerrors.ErrExternalLoginFailed
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, domainerrors.ErrEmailNotVerified
+	}
+
+	user, err := s.findOrCreateExternalUser(ctx, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markEmailVerified(ctx, user, "oidc provider "+providerName); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// findOrCreateExternalUser creates accounts without a password, they can only sign in through a provider
+func (s *UserServiceImpl) findOrCreateExternalUser(ctx context.Context, email string) (*model.User, error) {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err == nil {
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Printf("userService.findOrCreateExternalUser: Failed to fetch user from database: %v", err)
+		return nil, err
+	}
+
+	if _, createErr := s.repo.Create(ctx, model.User{Email: email, Role: utils.DefaultRole(), EmailVerified: true}); createErr != nil {
+		// another callback may have created the user in the meantime
+		log.Printf("userService.findOrCreateExternalUser: %v", createErr)
+	}
+
+	user, err = s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		log.Printf("userService.findOrCreateExternalUser: Failed to fetch created user: %v", err)
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
+
+	return user, nil
+}
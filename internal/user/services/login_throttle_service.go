@@ -0,0 +1,71 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/utils"
+	"context"
+	"time"
+)
+
+// LoginBlockedError is returned while an account has to wait before the next password attempt
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return domainerrors.ErrAccountLocked.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return domainerrors.ErrAccountLocked
+}
+
+// checkLoginBlock refuses the attempt without looking at the password while the account is backing off or locked
+func (s *UserServiceImpl) checkLoginBlock(ctx context.Context, email string) error {
+	until, err := s.redisRepo.GetLoginBlock(ctx, email)
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(until); wait > 0 {
+		return &LoginBlockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts the failed attempt and blocks the next ones once the back-off threshold is reached
+func (s *UserServiceImpl) recordLoginFailure(ctx context.Context, email string) {
+	failures, err := s.redisRepo.RecordLoginFailure(ctx, email)
+	if err != nil {
+		return
+	}
+
+	wait := loginBackoff(failures)
+	if wait == 0 {
+		return
+	}
+	if failures >= int64(constants.LOGIN_LOCKOUT_THRESHOLD) {
+		utils.LogSecurityEvent("login_locked", "login locked for %s after %d failed attempts", constants.LOGIN_LOCKOUT_DURATION, failures)
+	}
+	s.redisRepo.BlockLogin(ctx, email, time.Now().Add(wait))
+}
+
+func (s *UserServiceImpl) resetLoginFailures(ctx context.Context, email string) {
+	s.redisRepo.ResetLoginFailures(ctx, email)
+}
+
+// loginBackoff doubles the wait with every failure past LOGIN_BACKOFF_THRESHOLD, from LOGIN_LOCKOUT_THRESHOLD on the account is locked
+func loginBackoff(failures int64) time.Duration {
+	if failures >= int64(constants.LOGIN_LOCKOUT_THRESHOLD) {
+		return constants.LOGIN_LOCKOUT_DURATION
+	}
+	if failures < int64(constants.LOGIN_BACKOFF_THRESHOLD) {
+		return 0
+	}
+
+	wait := constants.LOGIN_BACKOFF_BASE << (failures - int64(constants.LOGIN_BACKOFF_THRESHOLD))
+	if wait <= 0 || wait > constants.LOGIN_LOCKOUT_DURATION {
+		return constants.LOGIN_LOCKOUT_DURATION
+	}
+	return wait
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"backend-go/constants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	backoffThreshold, lockoutThreshold, lockoutDuration := constants.LOGIN_BACKOFF_THRESHOLD, constants.LOGIN_LOCKOUT_THRESHOLD, constants.LOGIN_LOCKOUT_DURATION
+	defer func() {
+		constants.LOGIN_BACKOFF_THRESHOLD, constants.LOGIN_LOCKOUT_THRESHOLD, constants.LOGIN_LOCKOUT_DURATION = backoffThreshold, lockoutThreshold, lockoutDuration
+	}()
+	constants.LOGIN_BACKOFF_THRESHOLD = 3
+	constants.LOGIN_LOCKOUT_THRESHOLD = 10
+	constants.LOGIN_LOCKOUT_DURATION = 15 * time.Minute
+
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second}, // first failure at the back-off threshold
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{9, 64 * time.Second},
+		{10, 15 * time.Minute}, // locked
+		{11, 15 * time.Minute},
+		{1000, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, loginBackoff(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestLoginBackoff_CappedAtLockout(t *testing.T) {
+	lockoutThreshold, lockoutDuration := constants.LOGIN_LOCKOUT_THRESHOLD, constants.LOGIN_LOCKOUT_DURATION
+	defer func() {
+		constants.LOGIN_LOCKOUT_THRESHOLD, constants.LOGIN_LOCKOUT_DURATION = lockoutThreshold, lockoutDuration
+	}()
+	// a lockout threshold far above the back-off threshold must neither exceed the lockout nor overflow the shift
+	constants.LOGIN_LOCKOUT_THRESHOLD = 200
+	constants.LOGIN_LOCKOUT_DURATION = time.Minute
+
+	assert.Equal(t, time.Minute, loginBackoff(int64(constants.LOGIN_BACKOFF_THRESHOLD)+10))
+	assert.Equal(t, time.Minute, loginBackoff(int64(constants.LOGIN_BACKOFF_THRESHOLD)+70))
+}
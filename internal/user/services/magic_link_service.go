@@ -0,0 +1,72 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/mailer"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RequestMagicLink emails a login link that only works from the ip address and browser that asked for it.
+// Unknown emails are not reported.
+func (s *UserServiceImpl) RequestMagicLink(ctx context.Context, email string, client userType.ClientInfo) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.issueOneTimeToken(ctx, constants.MAGIC_LINK_PURPOSE, user, client, constants.MAGIC_LINK_EXPIRATION)
+	if err != nil {
+		return err
+	}
+
+	link := constants.MAGIC_LINK_URL + "?token=" + url.QueryEscape(token)
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Open this link within %d minutes on the same device to log in:\n%s\n\nIf you did not ask for it, ignore this email.",
+			int(constants.MAGIC_LINK_EXPIRATION.Minutes()), link),
+	})
+
+	return nil
+}
+
+// LoginWithMagicLink redeems the link and signs the user in like a password login. The link is bound to the requesting
+// device by its user agent and, within the session ip binding policy, its address. The token is used up by the first attempt,
+// also one from another device, so a leaked link can not be retried.
+func (s *UserServiceImpl) LoginWithMagicLink(ctx context.Context, token string, client userType.ClientInfo) (*userType.UserResponse, error) {
+	magicLink, err := s.redisRepo.ConsumeOneTimeToken(ctx, constants.MAGIC_LINK_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if magicLink == nil {
+		return nil, domainerrors.ErrInvalidToken
+	}
+	if !utils.SessionIPAllowed(magicLink.IPAddress, client.IPAddress) || magicLink.UserAgent != client.UserAgent {
+		utils.LogSecurityEvent("magic_link_device_mismatch", "user %s link requested from %s, redeemed from %s", magicLink.UserID, magicLink.IPAddress, client.IPAddress)
+		return nil, domainerrors.ErrInvalidToken
+	}
+
+	user, err := s.findUser(ctx, magicLink.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != magicLink.Email {
+		return nil, domainerrors.ErrInvalidToken
+	}
+	if err := s.markEmailVerified(ctx, user, "magic link"); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
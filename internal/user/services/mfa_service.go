@@ -0,0 +1,269 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	model "backend-go/models"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// completeLogin issues the token pair, or only an MFA challenge token when the user has two factor authentication enabled
+func (s *UserServiceImpl) completeLogin(ctx context.Context, user *model.User, client userType.ClientInfo) (*userType.UserResponse, error) {
+	if user.Disabled {
+		return nil, domainerrors.ErrAccountDisabled
+	}
+	if !user.MFAEnabled {
+		return s.openLoginSession(ctx, user, client)
+	}
+	if _, err := loginScope(roleOf(user), client.Scope); err != nil {
+		return nil, err
+	}
+
+	mfaToken, err := utils.GenerateMFAToken(user.ID, user.Email, client.Scope)
+	if err != nil {
+		log.Printf("userService.completeLogin: error generating mfa token: %v", err)
+		return nil, domainerrors.ErrGeneratingJWTToken
+	}
+
+	return &userType.UserResponse{User: user, MFAToken: mfaToken}, nil
+}
+
+// EnrollMFA creates a new TOTP secret. It only becomes active once ConfirmMFA saw a valid code for it.
+func (s *UserServiceImpl) EnrollMFA(ctx context.Context, userId string) (*userType.MFAEnrollment, error) {
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, domainerrors.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		log.Printf("userService.EnrollMFA: %v", err)
+		return nil, err
+	}
+
+	if _, err := s.updateUserByID(ctx, userId, bson.M{"totp_pending_secret": encrypted}); err != nil {
+		return nil, err
+	}
+
+	return &userType.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(constants.MFA_ISSUER, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables two factor authentication and returns the recovery codes, they are only shown this once
+func (s *UserServiceImpl) ConfirmMFA(ctx context.Context, userId string, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, domainerrors.ErrMFAAlreadyEnabled
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, domainerrors.ErrMFANotEnrolled
+	}
+
+	attempts, err := s.redisRepo.IncrementMFAEnrollmentAttempts(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > constants.MFA_MAX_ATTEMPTS {
+		utils.LogSecurityEvent("mfa_attempts_exceeded", "user %s while confirming the enrollment", userId)
+		return nil, domainerrors.ErrTooManyMFAAttempts
+	}
+
+	secret, err := utils.DecryptSecret(user.TOTPPendingSecret)
+	if err != nil {
+		log.Printf("userService.ConfirmMFA: %v", err)
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTPCode(secret, code, time.Now())
+	if !ok {
+		return nil, domainerrors.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
+
+	updates := bson.M{
+		"mfa_enabled":         true,
+		"totp_secret":         user.TOTPPendingSecret,
+		"totp_pending_secret": "",
+		"totp_last_step":      step,
+		"recovery_codes":      hashes,
+	}
+	if _, err := s.updateUserByID(ctx, userId, updates); err != nil {
+		return nil, err
+	}
+	utils.LogSecurityEvent("mfa_enabled", "user %s enabled two factor authentication", userId)
+
+	return codes, nil
+}
+
+// VerifyMFA completes a login started by Login with the second factor. Every challenge token allows a few attempts and is single use,
+// wrong codes also count as failed logins of the account so fetching new challenges does not allow more guesses.
+func (s *UserServiceImpl) VerifyMFA(ctx context.Context, req userType.MFARequest, client userType.ClientInfo) (*userType.UserResponse, error) {
+	claims, err := utils.VerifyAndParseJWTToken(req.MFAToken, utils.MFATokenType)
+	if err != nil {
+		return nil, domainerrors.ErrInvalidToken
+	}
+
+	used, err := s.redisRepo.IsMFAChallengeUsed(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, domainerrors.ErrInvalidToken
+	}
+
+	attempts, err := s.redisRepo.IncrementMFAAttempts(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > constants.MFA_MAX_ATTEMPTS {
+		utils.LogSecurityEvent("mfa_attempts_exceeded", "user %s ip %s", claims.UserID, client.IPAddress)
+		return nil, domainerrors.ErrTooManyMFAAttempts
+	}
+
+	user, err := s.findUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		return nil, domainerrors.ErrInvalidToken
+	}
+	if err := s.checkLoginBlock(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if req.RecoveryCode != "" {
+		err = s.useRecoveryCode(ctx, user, req.RecoveryCode)
+	} else {
+		err = s.useTOTPCode(ctx, user, req.Code)
+	}
+	if errors.Is(err, domainerrors.ErrInvalidMFACode) {
+		s.recordLoginFailure(ctx, user.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the challenge is used up, of two concurrent requests with the same token only one gets a session
+	fresh, err := s.redisRepo.MarkMFAChallengeUsed(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, domainerrors.ErrInvalidToken
+	}
+
+	client.Scope = claims.Scope
+	return s.openLoginSession(ctx, user, client)
+}
+
+// openLoginSession creates the session of a login that passed every factor and clears the failed attempts of the account
+func (s *UserServiceImpl) openLoginSession(ctx context.Context, user *model.User, client userType.ClientInfo) (*userType.UserResponse, error) {
+	res, err := s.createSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	s.resetLoginFailures(ctx, user.Email)
+	return res, nil
+}
+
+func (s *UserServiceImpl) useTOTPCode(ctx context.Context, user *model.User, code string) error {
+	secret, err := utils.DecryptSecret(user.TOTPSecret)
+	if err != nil {
+		log.Printf("userService.useTOTPCode: %v", err)
+		return err
+	}
+
+	step, ok := utils.ValidateTOTPCode(secret, code, time.Now())
+	if !ok {
+		return domainerrors.ErrInvalidMFACode
+	}
+	fresh, err := s.repo.SetTOTPLastStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		utils.LogSecurityEvent("totp_code_replayed", "user %s presented an already used code", user.ID)
+		return domainerrors.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *UserServiceImpl) useRecoveryCode(ctx context.Context, user *model.User, code string) error {
+	consumed, err := s.repo.ConsumeRecoveryCode(ctx, user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return domainerrors.ErrInvalidMFACode
+	}
+	utils.LogSecurityEvent("mfa_recovery_code_used", "user %s signed in with a recovery code", user.ID)
+
+	// recovery codes change the user document, the cached profile has to go
+	if _, err := s.redisRepo.DeleteUser(ctx, user.ID); err != nil {
+		log.Printf("Failed to delete user profile in Redis: %v", err)
+	}
+
+	return nil
+}
+
+func (s *UserServiceImpl) findUser(ctx context.Context, userId string) (*model.User, error) {
+	user, err := s.repo.FindByID(ctx, userId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domainerrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// generateRecoveryCodes returns the codes to show to the user and their hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, constants.MFA_RECOVERY_CODES)
+	hashes := make([]string, 0, constants.MFA_RECOVERY_CODES)
+	for i := 0; i < constants.MFA_RECOVERY_CODES; i++ {
+		random := make([]byte, 10)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		// readable codes like "k3j5h-2m4n6", 50 random bits each
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
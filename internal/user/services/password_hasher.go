@@ -0,0 +1,55 @@
+package services
+
+import (
+	"backend-go/utils"
+	"log"
+)
+
+// PasswordHasher hashes new passwords with the current Argon2id parameters and still verifies older hashes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches, and whether the stored hash should be replaced
+	// because it is bcrypt or uses other parameters than the current ones
+	Verify(password string, encoded string) (bool, bool)
+}
+
+type Argon2idHasher struct {
+	params    utils.Argon2idParams
+	dummyHash string
+}
+
+func NewPasswordHasher(params utils.Argon2idParams) *Argon2idHasher {
+	h := &Argon2idHasher{params: params}
+
+	// accounts without a password, and unknown emails, are checked against this hash so they take as long as a real one
+	dummyHash, err := utils.HashPasswordArgon2id("not the password of any account", params)
+	if err != nil {
+		log.Printf("passwordHasher: failed to create dummy hash: %v", err)
+	}
+	h.dummyHash = dummyHash
+
+	return h
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	return utils.HashPasswordArgon2id(password, h.params)
+}
+
+// Verify never matches an empty hash, it is compared against the dummy hash instead
+func (h *Argon2idHasher) Verify(password string, encoded string) (bool, bool) {
+	if encoded == "" {
+		utils.VerifyPasswordHash(password, h.dummyHash)
+		return false, false
+	}
+
+	ok, params, err := utils.VerifyPasswordHash(password, encoded)
+	if err != nil {
+		log.Printf("passwordHasher.Verify: %v", err)
+		return false, false
+	}
+	if !ok {
+		return false, false
+	}
+
+	return true, params == nil || *params != h.params
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	domainerrors "backend-go/constants/errors"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"log"
+)
+
+// PasswordPolicy decides whether a new password is acceptable, it is checked wherever a password is set
+type PasswordPolicy interface {
+	Check(password string, email string) []userType.FieldError
+}
+
+type PasswordPolicyImpl struct {
+	rules    utils.PasswordRules
+	breached *utils.BreachedPasswords
+}
+
+// NewPasswordPolicy takes the rules and an optional breached password dataset
+func NewPasswordPolicy(rules utils.PasswordRules, breached *utils.BreachedPasswords) *PasswordPolicyImpl {
+	return &PasswordPolicyImpl{
+		rules:    rules,
+		breached: breached,
+	}
+}
+
+func (p *PasswordPolicyImpl) Check(password string, email string) []userType.FieldError {
+	var fieldErrors []userType.FieldError
+	for _, violation := range p.rules.Validate(password, email) {
+		fieldErrors = append(fieldErrors, userType.FieldError{Field: "password", Code: violation.Code, Message: violation.Message})
+	}
+
+	// the dataset is an extra check, the password is not refused when it can not be read
+	breached, err := p.breached.IsBreached(password)
+	if err != nil {
+		log.Printf("passwordPolicy.Check: Failed to read breached password dataset: %v", err)
+	}
+	if breached {
+		fieldErrors = append(fieldErrors, userType.FieldError{Field: "password", Code: "breached", Message: "appeared in a data breach, choose another password"})
+	}
+
+	return fieldErrors
+}
+
+// PasswordPolicyError carries every field error of a password that was refused
+type PasswordPolicyError struct {
+	Fields []userType.FieldError
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return domainerrors.ErrPasswordPolicy.Error()
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return domainerrors.ErrPasswordPolicy
+}
+
+func (s *UserServiceImpl) checkPassword(password string, email string) error {
+	if fieldErrors := s.passwordPolicy.Check(password, email); len(fieldErrors) > 0 {
+		return &PasswordPolicyError{Fields: fieldErrors}
+	}
+	return nil
+}
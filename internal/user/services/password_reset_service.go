@@ -0,0 +1,133 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	"backend-go/mailer"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RequestPasswordReset emails a reset link. Unknown emails are not reported so the endpoint can not be used to probe for accounts.
+func (s *UserServiceImpl) RequestPasswordReset(ctx context.Context, email string, client userType.ClientInfo) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.issueOneTimeToken(ctx, constants.PASSWORD_RESET_PURPOSE, user, client, constants.PASSWORD_RESET_EXPIRATION)
+	if err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("password_reset_requested", "user %s ip %s", user.ID, client.IPAddress)
+
+	link := constants.PASSWORD_RESET_URL + "?token=" + url.QueryEscape(token)
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\nOpen this link within %d minutes to choose a new password:\n%s\n\nIf it was not you, ignore this email.",
+			int(constants.PASSWORD_RESET_EXPIRATION.Minutes()), link),
+	})
+
+	return nil
+}
+
+// ResetPassword sets the new password and signs the user out of every session, which also invalidates all refresh tokens.
+// A password refused by the policy leaves the token valid, so the user can pick another one from the same link.
+// The token only works while the account still has the email it was sent to, a link to an address changed since is dead.
+func (s *UserServiceImpl) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	pendingToken, err := s.redisRepo.GetOneTimeToken(ctx, constants.PASSWORD_RESET_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if pendingToken == nil {
+		return domainerrors.ErrInvalidToken
+	}
+	if err := s.checkPassword(newPassword, pendingToken.Email); err != nil {
+		return err
+	}
+
+	resetToken, err := s.redisRepo.ConsumeOneTimeToken(ctx, constants.PASSWORD_RESET_PURPOSE, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if resetToken == nil {
+		return domainerrors.ErrInvalidToken
+	}
+	user, err := s.findUser(ctx, resetToken.UserID)
+	if err != nil {
+		if errors.Is(err, domainerrors.ErrUserNotFound) {
+			return domainerrors.ErrInvalidToken
+		}
+		return err
+	}
+	if user.Email != resetToken.Email {
+		utils.LogSecurityEvent("password_reset_email_mismatch", "user %s presented a reset token sent to a previous email", user.ID)
+		return domainerrors.ErrInvalidToken
+	}
+
+	hashed, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return domainerrors.ErrSomethingWentWrong
+	}
+	if _, err := s.updateUserByID(ctx, resetToken.UserID, bson.M{"password": hashed, "token": ""}); err != nil {
+		return err
+	}
+
+	// no session is current, all of them end
+	revoked, err := s.RevokeOtherSessions(ctx, resetToken.UserID, "")
+	if err != nil {
+		return err
+	}
+	utils.LogSecurityEvent("password_reset", "user %s reset the password, %d sessions revoked", resetToken.UserID, revoked)
+
+	return nil
+}
+
+// issueOneTimeToken creates an emailed single use token, only its hash is stored
+func (s *UserServiceImpl) issueOneTimeToken(ctx context.Context, purpose string, user *model.User, client userType.ClientInfo, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken(constants.ONE_TIME_TOKEN_BYTES)
+	if err != nil {
+		log.Printf("userService.issueOneTimeToken: %v", err)
+		return "", domainerrors.ErrSomethingWentWrong
+	}
+
+	value := rdsModel.OneTimeToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now().Unix(),
+	}
+	if _, err := s.redisRepo.StoreOneTimeToken(ctx, purpose, utils.HashToken(token), value, ttl); err != nil {
+		return "", domainerrors.ErrStoringTokenInRedis
+	}
+
+	return token, nil
+}
+
+// sendMail delivers in the background, the response time must not depend on whether an email was sent
+func (s *UserServiceImpl) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("userService.sendMail: failed to send %q: %v", msg.Subject, err)
+		}
+	}()
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
+	repository "backend-go/internal/user/repository/mongoDb"
+	redisRepository "backend-go/internal/user/repository/redis"
+	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
+	"backend-go/utils"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TokenValidator holds the checks a token has to pass besides its signature: denylist, session and client ip binding.
+// It is shared by AuthMiddleware and the OAuth introspection endpoint.
+type TokenValidator interface {
+	ValidateAccessToken(ctx context.Context, accessToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error)
+	ValidateRefreshToken(ctx context.Context, refreshToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error)
+	InspectRefreshToken(ctx context.Context, refreshToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error)
+	ValidateAPIKey(ctx context.Context, rawKey string) (*utils.Claims, *model.APIKey, error)
+	ValidateServiceToken(ctx context.Context, serviceToken string) (*utils.Claims, error)
+	TouchSession(ctx context.Context, sessionID string) (interface{}, error)
+}
+
+type TokenValidatorImpl struct {
+	redisRepo  redisRepository.UserRedisRepository
+	apiKeyRepo repository.APIKeyRepository
+	repo       repository.UserRepository
+}
+
+func NewTokenValidator(redisRepo redisRepository.UserRedisRepository, apiKeyRepo repository.APIKeyRepository, r repository.UserRepository) *TokenValidatorImpl {
+	return &TokenValidatorImpl{
+		redisRepo:  redisRepo,
+		apiKeyRepo: apiKeyRepo,
+		repo:       r,
+	}
+}
+
+// ValidateAccessToken returns the claims and session of an active access token.
+// An empty clientIp skips the ip binding check, e.g. when the caller does not know the end user's address.
+func (v *TokenValidatorImpl) ValidateAccessToken(ctx context.Context, accessToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error) {
+	claims, tokenErr := utils.VerifyAndParseJWTToken(accessToken, utils.AccessTokenType)
+	if tokenErr != nil {
+		log.Printf("tokenValidator: invalid access token: %v", tokenErr)
+		return nil, nil, domainerrors.ErrInvalidToken
+	}
+
+	// Check blacklist access token
+	isBlacklisted, err := v.redisRepo.IsBlacklistedAccessToken(ctx, claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isBlacklisted {
+		log.Printf("Token %s for user %s is blacklisted", claims.ID, claims.UserID)
+		return nil, nil, domainerrors.ErrTokenRevoked
+	}
+
+	session, err := v.sessionOf(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkClientIP(session, clientIp); err != nil {
+		return nil, nil, err
+	}
+
+	return claims, session, nil
+}
+
+// ValidateRefreshToken returns the claims and session of a refresh token that is still the current one of its session.
+// A validly signed refresh token that was already rotated is evidence of theft, its whole session is revoked.
+func (v *TokenValidatorImpl) ValidateRefreshToken(ctx context.Context, refreshToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error) {
+	return v.checkRefreshToken(ctx, refreshToken, clientIp, true)
+}
+
+// InspectRefreshToken runs the checks of ValidateRefreshToken without acting on them, a rotated token is reported as
+// reused but its session is left alone. Introspection is a read-only query and must not sign the user out.
+func (v *TokenValidatorImpl) InspectRefreshToken(ctx context.Context, refreshToken string, clientIp string) (*utils.Claims, *rdsModel.Session, error) {
+	return v.checkRefreshToken(ctx, refreshToken, clientIp, false)
+}
+
+func (v *TokenValidatorImpl) checkRefreshToken(ctx context.Context, refreshToken string, clientIp string, revokeOnReuse bool) (*utils.Claims, *rdsModel.Session, error) {
+	claims, tokenErr := utils.VerifyAndParseJWTToken(refreshToken, utils.RefreshTokenType)
+	if tokenErr != nil {
+		log.Printf("tokenValidator: invalid refresh token: %v", tokenErr)
+		return nil, nil, domainerrors.ErrInvalidToken
+	}
+
+	session, err := v.sessionOf(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RefreshToken != refreshToken {
+		if !revokeOnReuse {
+			return nil, nil, domainerrors.ErrRefreshTokenReused
+		}
+		utils.LogSecurityEvent("refresh_token_reuse", "user %s session %s presented an already rotated refresh token from ip %s, revoking session", session.UserID, session.SessionID, clientIp)
+		if _, err := v.redisRepo.DeleteSession(ctx, session.UserID, session.SessionID); err != nil {
+			log.Printf("Failed to revoke session %s after refresh token reuse: %v", session.SessionID, err)
+		}
+		return nil, nil, domainerrors.ErrRefreshTokenReused
+	}
+	if err := checkClientIP(session, clientIp); err != nil {
+		return nil, nil, err
+	}
+
+	return claims, session, nil
+}
+
+// ValidateAPIKey resolves a personal API key to claims like those of an access token. The role is the user's current one,
+// API keys are not bound to a session or an ip address.
+func (v *TokenValidatorImpl) ValidateAPIKey(ctx context.Context, rawKey string) (*utils.Claims, *model.APIKey, error) {
+	if !strings.HasPrefix(rawKey, constants.API_KEY_PREFIX) {
+		return nil, nil, domainerrors.ErrInvalidToken
+	}
+
+	key, err := v.apiKeyRepo.FindByHash(ctx, utils.HashToken(rawKey))
+	if err != nil {
+		log.Printf("tokenValidator: failed to find api key: %v", err)
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key == nil || now.Unix() >= key.ExpiresAt {
+		return nil, nil, domainerrors.ErrInvalidToken
+	}
+
+	user, err := v.userOf(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, domainerrors.ErrAccountDisabled
+	}
+
+	if now.Unix()-key.LastUsedAt >= int64(constants.API_KEY_LAST_USED_INTERVAL.Seconds()) {
+		if err := v.apiKeyRepo.SetLastUsed(ctx, key.ID, now.Unix()); err != nil {
+			log.Printf("Failed to update last use of api key %s: %v", key.ID, err)
+		}
+		key.LastUsedAt = now.Unix()
+	}
+
+	claims := &utils.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      roleOf(user),
+		Scope:     strings.Join(key.Scopes, " "),
+		TokenType: utils.APIKeyTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        key.ID,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(time.Unix(key.CreatedAt, 0)),
+			ExpiresAt: jwt.NewNumericDate(time.Unix(key.ExpiresAt, 0)),
+		},
+	}
+	return claims, key, nil
+}
+
+// ValidateServiceToken returns the claims of an active client credentials token. Service tokens have no session,
+// they can only be revoked through the denylist.
+func (v *TokenValidatorImpl) ValidateServiceToken(ctx context.Context, serviceToken string) (*utils.Claims, error) {
+	claims, tokenErr := utils.VerifyAndParseJWTToken(serviceToken, utils.ServiceTokenType)
+	if tokenErr != nil {
+		log.Printf("tokenValidator: invalid service token: %v", tokenErr)
+		return nil, domainerrors.ErrInvalidToken
+	}
+
+	isBlacklisted, err := v.redisRepo.IsBlacklistedAccessToken(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if isBlacklisted {
+		log.Printf("Token %s for client %s is blacklisted", claims.ID, claims.ClientID)
+		return nil, domainerrors.ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+func (v *TokenValidatorImpl) TouchSession(ctx context.Context, sessionID string) (interface{}, error) {
+	return v.redisRepo.TouchSession(ctx, sessionID)
+}
+
+// userOf reads the owner of an API key through the profile cache (cache aside strategy)
+func (v *TokenValidatorImpl) userOf(ctx context.Context, userId string) (*model.User, error) {
+	if cached, err := v.redisRepo.GetUser(ctx, userId); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	user, err := v.repo.FindByID(ctx, userId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domainerrors.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if _, err := v.redisRepo.SaveUser(ctx, *user); err != nil {
+		log.Printf("Failed to save user profile in Redis: %v", err)
+	}
+
+	return user, nil
+}
+
+// sessionOf looks up the session the token was issued for
+func (v *TokenValidatorImpl) sessionOf(ctx context.Context, claims *utils.Claims) (*rdsModel.Session, error) {
+	session, err := v.redisRepo.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		log.Printf("Failed to get user session from Redis: %v", err)
+		return nil, err
+	}
+	if session == nil || session.UserID != claims.UserID {
+		return nil, domainerrors.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// checkClientIP compares the ip address of the request with the one the session was opened from, as the ip binding policy demands
+func checkClientIP(session *rdsModel.Session, clientIp string) error {
+	if clientIp != "" && !utils.SessionIPAllowed(session.IPAddress, clientIp) {
+		log.Printf("IP address mismatch: session IP %s, request IP %s", session.IPAddress, clientIp)
+		return domainerrors.ErrClientIPMismatch
+	}
+	return nil
+}
@@ -5,87 +5,204 @@ import (
 	domainerrors "backend-go/constants/errors"
 	repository "backend-go/internal/user/repository/mongoDb"
 	redisRepository "backend-go/internal/user/repository/redis"
+	"backend-go/mailer"
 	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
 	userType "backend-go/type"
 	"backend-go/utils"
 	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
 	Register(ctx context.Context, creds model.User) (interface{}, error)
-	Login(ctx context.Context, email string, password string, clientIp string) (*userType.UserResponse, error)
+	Login(ctx context.Context, email string, password string, client userType.ClientInfo) (*userType.UserResponse, error)
 	Profile(ctx context.Context, UserId string, clientIp string) (*model.User, error)
-	Logout(ctx context.Context, userId string, accessToken string) (interface{}, error)
-	GetSilentAccessToken(ctx context.Context, userId string, email string, clientIp string) (string, error)
+	Logout(ctx context.Context, userId string, sessionId string, accessTokenId string, accessTokenExpiry time.Time) (interface{}, error)
+	GetSilentAccessToken(ctx context.Context, userId string, email string, sessionId string, refreshToken string) (*userType.UserResponse, error)
+	ListSessions(ctx context.Context, userId string, currentSessionId string) ([]userType.SessionInfo, error)
+	RevokeSession(ctx context.Context, userId string, sessionId string) (interface{}, error)
+	RevokeOtherSessions(ctx context.Context, userId string, currentSessionId string) (int, error)
+	EnrollMFA(ctx context.Context, userId string) (*userType.MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, userId string, code string) ([]string, error)
+	VerifyMFA(ctx context.Context, req userType.MFARequest, client userType.ClientInfo) (*userType.UserResponse, error)
+	RequestMagicLink(ctx context.Context, email string, client userType.ClientInfo) error
+	LoginWithMagicLink(ctx context.Context, token string, client userType.ClientInfo) (*userType.UserResponse, error)
+	ResendVerificationEmail(ctx context.Context, email string, client userType.ClientInfo) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string, client userType.ClientInfo) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	ChangePassword(ctx context.Context, userId string, sessionId string, currentPassword string, newPassword string) (int, error)
+	RequestEmailChange(ctx context.Context, userId string, password string, newEmail string, client userType.ClientInfo) error
+	ApproveEmailChange(ctx context.Context, token string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	StartExternalLogin(ctx context.Context, providerName string) (string, string, error)
+	CompleteExternalLogin(ctx context.Context, providerName string, code string, state string, client userType.ClientInfo) (*userType.UserResponse, error)
 }
 
 type UserServiceImpl struct {
-	repo      repository.UserRepository
-	redisRepo redisRepository.UserRedisRepository
+	repo           repository.UserRepository
+	redisRepo      redisRepository.UserRedisRepository
+	providers      map[string]*utils.OIDCProvider
+	mailer         mailer.Mailer
+	hasher         PasswordHasher
+	passwordPolicy PasswordPolicy
 }
 
-func NewUserService(r repository.UserRepository, redisRepo redisRepository.UserRedisRepository) *UserServiceImpl {
+func NewUserService(r repository.UserRepository, redisRepo redisRepository.UserRedisRepository, providers map[string]*utils.OIDCProvider, m mailer.Mailer, hasher PasswordHasher, passwordPolicy PasswordPolicy) *UserServiceImpl {
 	return &UserServiceImpl{
-		repo:      r,
-		redisRepo: redisRepo,
+		repo:           r,
+		redisRepo:      redisRepo,
+		providers:      providers,
+		mailer:         m,
+		hasher:         hasher,
+		passwordPolicy: passwordPolicy,
 	}
 }
 
+// Register creates the account and emails a verification link. Only the credentials are taken from the request,
+// state like the role or email_verified can not be set by the client.
 func (s *UserServiceImpl) Register(ctx context.Context, creds model.User) (interface{}, error) {
-	res, err := s.repo.Create(ctx, creds)
+	if err := s.checkPassword(creds.Password, creds.Email); err != nil {
+		return nil, err
+	}
+
+	hashed, err := s.hasher.Hash(creds.Password)
+	if err != nil {
+		log.Printf("userService.Register: Failed to hash password: %v", err)
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
+
+	newUser := model.User{
+		Email:    creds.Email,
+		Password: hashed,
+		Role:     utils.DefaultRole(),
+	}
+	res, err := s.repo.Create(ctx, newUser)
 	if err != nil {
 		log.Printf("Failed to create user: %v", err)
 		return nil, err
 	}
 
+	user, err := s.repo.FindByEmail(ctx, creds.Email)
+	if err != nil {
+		log.Printf("userService.Register: Failed to fetch created user: %v", err)
+		return res, nil
+	}
+	if err := s.sendVerificationEmail(ctx, user, userType.ClientInfo{}); err != nil {
+		// the user can ask for a new link
+		log.Printf("userService.Register: Failed to send verification email: %v", err)
+	}
+
 	return res, nil
 }
 
-func (s *UserServiceImpl) Login(ctx context.Context, email string, password string, clientIp string) (*userType.UserResponse, error) {
+// Login checks the password. Failures are counted per email, unknown or not, and an unknown email fails
+// exactly like a wrong password so the response does not reveal which accounts exist.
+func (s *UserServiceImpl) Login(ctx context.Context, email string, password string, client userType.ClientInfo) (*userType.UserResponse, error) {
+	if err := s.checkLoginBlock(ctx, email); err != nil {
+		return nil, err
+	}
+
 	//check if user exists
 	user, err := s.repo.FindByEmail(ctx, email)
-	if err != nil {
+	if err != nil && err != mongo.ErrNoDocuments {
 		log.Printf("userService_Login:Failed to fetch user from database: %v", err)
-		if err == mongo.ErrNoDocuments {
-			return nil, domainerrors.ErrUserNotFound
-		}
 		return nil, err
 	}
 	if user == nil {
-		return nil, domainerrors.ErrUserNotFound
+		s.hasher.Verify(password, "")
+		s.recordLoginFailure(ctx, email)
+		return nil, domainerrors.ErrInvalidCredentials
 	}
 
 	//compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	ok, needsRehash := s.hasher.Verify(password, user.Password)
+	if !ok {
+		s.recordLoginFailure(ctx, email)
 		return nil, domainerrors.ErrInvalidCredentials
 	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, password)
+	}
+	if constants.REQUIRE_EMAIL_VERIFICATION && !user.EmailVerified {
+		return nil, domainerrors.ErrEmailNotVerified
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// rehashPassword upgrades a bcrypt hash, or one with outdated parameters, while the plain password is at hand.
+// The login goes on if it fails, the hash is upgraded at the next one.
+func (s *UserServiceImpl) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hashed, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("userService.rehashPassword: Failed to hash password: %v", err)
+		return
+	}
+	if _, err := s.updateUserByID(ctx, user.ID, bson.M{"password": hashed}); err != nil {
+		log.Printf("userService.rehashPassword: Failed to store upgraded hash: %v", err)
+		return
+	}
+	user.Password = hashed
+}
+
+// createSession opens a new login session for the user and issues the token pair bound to it
+func (s *UserServiceImpl) createSession(ctx context.Context, user *model.User, client userType.ClientInfo) (*userType.UserResponse, error) {
+	if user.Disabled {
+		return nil, domainerrors.ErrAccountDisabled
+	}
+
+	sessionID, err := utils.GenerateSecureToken(constants.SESSION_ID_BYTES)
+	if err != nil {
+		log.Printf("Error generating session id: %v", err)
+		return nil, domainerrors.ErrSomethingWentWrong
+	}
 
-	// Generate JWT token
-	accessToken, errAcessToken := utils.GenerateAccessToken(user.ID, user.Email)
-	refreshToken, errRefreshToken := utils.GenerateRefreshToken(user.ID, user.Email)
+	// Generate JWT token, its scope is capped by the role
+	role := roleOf(user)
+	scope, err := loginScope(role, client.Scope)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, errAcessToken := utils.GenerateAccessToken(user.ID, user.Email, role, sessionID, scope)
+	refreshToken, errRefreshToken := utils.GenerateRefreshToken(user.ID, user.Email, role, sessionID)
 
 	if errAcessToken != nil || errRefreshToken != nil {
 		fmt.Print("Error generating JWT token", errAcessToken, errRefreshToken)
 		return nil, domainerrors.ErrGeneratingJWTToken
 	}
 
-	// store token in redis and mongodb
-	if _, storeErr := s.redisRepo.StoreToken(ctx, user.ID, refreshToken, clientIp); storeErr != nil {
-		fmt.Print("Error storing token in redis", storeErr)
+	// store session in redis and last login in mongodb
+	now := time.Now().Unix()
+	session := rdsModel.Session{
+		SessionID:    sessionID,
+		UserID:       user.ID,
+		RefreshToken: refreshToken,
+		IPAddress:    client.IPAddress,
+		UserAgent:    client.UserAgent,
+		DeviceLabel:  client.DeviceLabel,
+		Scope:        scope,
+		CreatedAt:    now,
+		LastSeenAt:   now,
+	}
+	if _, storeErr := s.redisRepo.CreateSession(ctx, session); storeErr != nil {
+		fmt.Print("Error storing session in redis", storeErr)
 		return nil, domainerrors.ErrStoringTokenInRedis
 	}
 
 	updates := bson.M{
 		"token":      refreshToken,
-		"ip_address": clientIp,
+		"ip_address": client.IPAddress,
 	}
 	if _, dbErr := s.updateUserByID(ctx, user.ID, updates); dbErr != nil {
 		fmt.Print("Error storing token in Database", dbErr)
@@ -96,10 +213,21 @@ func (s *UserServiceImpl) Login(ctx context.Context, email string, password stri
 		User:         user,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
+		SessionID:    sessionID,
 	}
 	return resp, nil
 }
 
+// loginScope caps the scope asked for at login by the role. A session stored without a scope gets everything the role
+// grants at the next refresh, so asking only for scopes the role does not grant fails instead.
+func loginScope(role string, requested string) (string, error) {
+	granted := utils.GrantScopes(role, strings.Fields(requested))
+	if len(granted) == 0 && strings.TrimSpace(requested) != "" {
+		return "", domainerrors.ErrInvalidScope
+	}
+	return strings.Join(granted, " "), nil
+}
+
 // cache aside stategy for user profile
 func (s *UserServiceImpl) Profile(ctx context.Context, UserId string, clientIp string) (*model.User, error) {
 	//first check in redis cache
@@ -133,27 +261,130 @@ func (s *UserServiceImpl) Profile(ctx context.Context, UserId string, clientIp s
 	return cachedUser, nil
 }
 
-func (s *UserServiceImpl) Logout(ctx context.Context, userId string, accessToken string) (interface{}, error) {
-	_, err := s.redisRepo.DeleteToken(ctx, userId)
+func (s *UserServiceImpl) Logout(ctx context.Context, userId string, sessionId string, accessTokenId string, accessTokenExpiry time.Time) (interface{}, error) {
+	_, err := s.redisRepo.DeleteSession(ctx, userId, sessionId)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = s.redisRepo.SetBlacklistOfAccessToken(ctx, userId, accessToken, constants.ACCESS_TOKEN_EXPIRATION)
+	_, err = s.redisRepo.SetBlacklistOfAccessToken(ctx, accessTokenId, accessTokenExpiry)
 	if err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
-func (s *UserServiceImpl) GetSilentAccessToken(ctx context.Context, userId string, email string, clientIp string) (string, error) {
-	accessToken, errAcessToken := utils.GenerateAccessToken(userId, email)
-	if errAcessToken != nil {
-		fmt.Print("Error generating JWT token", errAcessToken)
-		return "", domainerrors.ErrGeneratingJWTToken
+
+// GetSilentAccessToken issues a new access token and rotates the refresh token of the session.
+// The presented refresh token is invalidated, presenting it again revokes the whole session.
+// The role and email are read again, so changes to them take effect with the next refresh. The scope granted at login
+// is capped by the current role.
+func (s *UserServiceImpl) GetSilentAccessToken(ctx context.Context, userId string, email string, sessionId string, refreshToken string) (*userType.UserResponse, error) {
+	user, err := s.Profile(ctx, userId, "")
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, domainerrors.ErrAccountDisabled
+	}
+	session, err := s.redisRepo.GetSession(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userId {
+		return nil, domainerrors.ErrSessionNotFound
 	}
 
-	return accessToken, nil
+	role := roleOf(user)
+	scope := strings.Join(utils.GrantScopes(role, strings.Fields(session.Scope)), " ")
+	accessToken, errAcessToken := utils.GenerateAccessToken(userId, user.Email, role, sessionId, scope)
+	newRefreshToken, errRefreshToken := utils.GenerateRefreshToken(userId, user.Email, role, sessionId)
+	if errAcessToken != nil || errRefreshToken != nil {
+		fmt.Print("Error generating JWT token", errAcessToken, errRefreshToken)
+		return nil, domainerrors.ErrGeneratingJWTToken
+	}
+
+	rotated, err := s.redisRepo.RotateRefreshToken(ctx, sessionId, refreshToken, newRefreshToken)
+	if err != nil {
+		return nil, domainerrors.ErrStoringTokenInRedis
+	}
+	if !rotated {
+		// another request already rotated this token
+		if _, err := s.revokeTokenFamily(ctx, userId, sessionId); err != nil {
+			return nil, err
+		}
+		return nil, domainerrors.ErrRefreshTokenReused
+	}
+
+	resp := &userType.UserResponse{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		SessionID:    sessionId,
+	}
+	return resp, nil
+}
+
+// revokeTokenFamily ends the session a reused refresh token belongs to, which invalidates every token issued for it
+func (s *UserServiceImpl) revokeTokenFamily(ctx context.Context, userId string, sessionId string) (interface{}, error) {
+	utils.LogSecurityEvent("refresh_token_reuse", "user %s session %s presented an already rotated refresh token, revoking session", userId, sessionId)
+
+	return s.redisRepo.DeleteSession(ctx, userId, sessionId)
+}
+
+func (s *UserServiceImpl) ListSessions(ctx context.Context, userId string, currentSessionId string) ([]userType.SessionInfo, error) {
+	sessions, err := s.redisRepo.ListSessions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt > sessions[j].LastSeenAt
+	})
+
+	result := make([]userType.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, userType.SessionInfo{
+			Session: session,
+			Current: session.SessionID == currentSessionId,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *UserServiceImpl) RevokeSession(ctx context.Context, userId string, sessionId string) (interface{}, error) {
+	session, err := s.redisRepo.GetSession(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	// never reveal sessions of other users
+	if session == nil || session.UserID != userId {
+		return nil, domainerrors.ErrSessionNotFound
+	}
+
+	return s.redisRepo.DeleteSession(ctx, userId, sessionId)
+}
+
+// RevokeOtherSessions signs the user out everywhere except the session making the request
+func (s *UserServiceImpl) RevokeOtherSessions(ctx context.Context, userId string, currentSessionId string) (int, error) {
+	sessions, err := s.redisRepo.ListSessions(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.SessionID == currentSessionId {
+			continue
+		}
+		if _, err := s.redisRepo.DeleteSession(ctx, userId, session.SessionID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	log.Printf("userService.RevokeOtherSessions: revoked %d sessions of user %s", revoked, userId)
+
+	return revoked, nil
 }
 
 func (s *UserServiceImpl) FindByEmail(ctx context.Context, email string) (interface{}, error) {
@@ -199,3 +430,11 @@ func (s *UserServiceImpl) updateUserByID(ctx context.Context, userId string, upd
 
 	return updatedUser, nil
 }
+
+// roleOf treats accounts stored without a role (created before roles were enforced) as having the default role
+func roleOf(user *model.User) string {
+	if user.Role == "" {
+		return utils.DefaultRole()
+	}
+	return user.Role
+}
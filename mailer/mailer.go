@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"backend-go/config"
+	"context"
+	"log"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Mailer delivers transactional emails like password reset links
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer uses SMTP when SMTP_HOST is set, otherwise emails are only written to the log for local development
+func NewMailer() Mailer {
+	host := config.GetEnv("SMTP_HOST", "")
+	if host == "" {
+		log.Println("⚠️  SMTP_HOST not set, emails are written to the log")
+		return NewLogMailer()
+	}
+
+	return NewSMTPMailer(SMTPConfig{
+		Host:     host,
+		Port:     config.GetEnv("SMTP_PORT", "587"),
+		Username: config.GetEnv("SMTP_USERNAME", ""),
+		Password: smtpPassword(),
+		From:     config.GetEnv("MAIL_FROM", "no-reply@localhost"),
+	})
+}
+
+// smtpPassword is read directly, config.GetEnv echoes values to stdout
+func smtpPassword() string {
+	password, _ := os.LookupEnv("SMTP_PASSWORD")
+	return password
+}
+
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("mailer: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,118 @@
+package mailer_test
+
+import (
+	"backend-go/mailer"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer accepts a single message without TLS or authentication, a local stand-in for a real relay
+type fakeSMTPServer struct {
+	listener net.Listener
+	received chan receivedMail
+}
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeSMTPServer{listener: listener, received: make(chan receivedMail, 1)}
+	go server.serve()
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	var mail receivedMail
+	tp.PrintfLine("220 localhost fake smtp")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			mail.from = strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end with <CRLF>.<CRLF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			s.received <- mail
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) config() mailer.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return mailer.SMTPConfig{Host: host, Port: port, From: "no-reply@backend-go.local"}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	m := mailer.NewSMTPMailer(server.config())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.Send(ctx, mailer.Message{
+		To:      "test@gmail.com",
+		Subject: "Reset your password",
+		Body:    "Open this link:\nhttp://localhost/reset?token=abc",
+	})
+	require.NoError(t, err)
+
+	select {
+	case mail := <-server.received:
+		assert.Equal(t, "no-reply@backend-go.local", mail.from)
+		assert.Equal(t, []string{"test@gmail.com"}, mail.to)
+		assert.Contains(t, mail.data, "Subject: Reset your password")
+		assert.Contains(t, mail.data, "http://localhost/reset?token=abc")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+}
+
+func TestSMTPMailer_RejectsHeaderInjection(t *testing.T) {
+	m := mailer.NewSMTPMailer(mailer.SMTPConfig{Host: "127.0.0.1", Port: "1"})
+
+	err := m.Send(context.Background(), mailer.Message{
+		To:      "test@gmail.com\r\nBcc: victim@gmail.com",
+		Subject: "hello",
+	})
+	assert.Error(t, err)
+}
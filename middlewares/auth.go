@@ -6,21 +6,25 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"time"
+	"strings"
 
-	"backend-go/config"
+	"backend-go/constants"
+	domainerrors "backend-go/constants/errors"
 	contextkeys "backend-go/contextKeys"
-	redisRepository "backend-go/internal/user/repository/redis"
+	"backend-go/internal/user/services"
 	userType "backend-go/type"
 	"backend-go/utils"
 )
 
-// AuthMiddleware checks for a valid JWT token in the request header
+// AuthMiddleware checks for a valid JWT token in the request header, a personal API key or a service token
+// of the client credentials grant is accepted in its place
 
-func AuthMiddleware(next http.Handler, UserRedisRepo redisRepository.UserRedisRepository) http.Handler {
+func AuthMiddleware(next http.Handler, tokenValidator services.TokenValidator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		accessToken, errToken := utils.ExtractTokenFromHeader(r)
 		if errToken != nil || accessToken == "" {
@@ -29,50 +33,34 @@ func AuthMiddleware(next http.Handler, UserRedisRepo redisRepository.UserRedisRe
 			return
 		}
 
-		//verify the token
-		claims, tokenErr := utils.VerifyAndParseJWTToken(accessToken)
-		if tokenErr != nil {
-			fmt.Println("Token from header:", claims, tokenErr)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Check blacklist access token
-		isBlacklisted, err := UserRedisRepo.IsBlacklistedAccessToken(r.Context(), claims.UserID, accessToken)
-		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+		if strings.HasPrefix(accessToken, constants.API_KEY_PREFIX) {
+			authenticateAPIKey(w, r, next, tokenValidator, accessToken)
 			return
 		}
-		if isBlacklisted {
-			log.Printf("Token %s for user %s is blacklisted", accessToken, claims.UserID)
-			http.Error(w, "unauthorized access", http.StatusUnauthorized)
-			return
-		}
-
-		// verify token expiry
-		unixTimeSeconds := time.Now().Unix()
-		if claims.ExpiresAt == nil || claims.ExpiresAt.Unix() < unixTimeSeconds {
-			http.Error(w, "Token expired", http.StatusUnauthorized)
+		if utils.UnverifiedTokenType(accessToken) == utils.ServiceTokenType {
+			authenticateService(w, r, next, tokenValidator, accessToken)
 			return
 		}
 
-		//verify the token ip address with the stored ip address in redis
-		storedRdxToken, err := UserRedisRepo.GetToken(r.Context(), claims.UserID)
+		//verify the token, its session and the client ip bound to the session
+		clientIp := utils.GetClientIP(r)
+		claims, session, err := tokenValidator.ValidateAccessToken(r.Context(), accessToken, clientIp)
 		if err != nil {
-			log.Printf("Failed to get user session from Redis: %v", err)
-			http.Error(w, "internastoredDatal error", http.StatusInternalServerError)
-			return
-		}
-		if storedRdxToken == nil {
-			http.Error(w, "unauthorized access", http.StatusUnauthorized)
+			switch {
+			case errors.Is(err, domainerrors.ErrInvalidToken):
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+			case errors.Is(err, domainerrors.ErrTokenRevoked),
+				errors.Is(err, domainerrors.ErrSessionNotFound),
+				errors.Is(err, domainerrors.ErrClientIPMismatch):
+				http.Error(w, "unauthorized access", http.StatusUnauthorized)
+			default:
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		clientIp := utils.GetClientIP(r, config.IsLocal())
-		if storedRdxToken.IPAddress != clientIp {
-			log.Printf("IP address mismatch: token IP %s, request IP %s", storedRdxToken.IPAddress, clientIp)
-			http.Error(w, "unauthorized access", http.StatusUnauthorized)
-			return
+		if _, err := tokenValidator.TouchSession(r.Context(), session.SessionID); err != nil {
+			log.Printf("Failed to update last seen of session %s: %v", session.SessionID, err)
 		}
 
 		// TODO: Extract user from the DB using claims.UserID if needed
@@ -87,3 +75,72 @@ func AuthMiddleware(next http.Handler, UserRedisRepo redisRepository.UserRedisRe
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, tokenValidator services.TokenValidator, rawKey string) {
+	claims, apiKey, err := tokenValidator.ValidateAPIKey(r.Context(), rawKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken):
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrAccountDisabled):
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+		default:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	userContents := userType.UserContents{
+		Claims: claims,
+		APIKey: apiKey,
+	}
+	ctx := context.WithValue(r.Context(), contextkeys.UserKey, userContents)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func authenticateService(w http.ResponseWriter, r *http.Request, next http.Handler, tokenValidator services.TokenValidator, serviceToken string) {
+	claims, err := tokenValidator.ValidateServiceToken(r.Context(), serviceToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domainerrors.ErrInvalidToken):
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		case errors.Is(err, domainerrors.ErrTokenRevoked):
+			http.Error(w, "unauthorized access", http.StatusUnauthorized)
+		default:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	userContents := userType.UserContents{
+		Claims:      claims,
+		AccessToken: serviceToken,
+	}
+	ctx := context.WithValue(r.Context(), contextkeys.UserKey, userContents)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireSession refuses API keys and service principals on routes that manage the account itself (sessions, credentials, API keys),
+// those need an interactive login. It has to be wrapped by AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+		if !ok || userContent.Claims == nil {
+			http.Error(w, "Could not get user info", http.StatusUnauthorized)
+			return
+		}
+		if userContent.APIKey != nil || userContent.IsServicePrincipal() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Forbidden",
+				"message": "API keys and service tokens can not be used for this route, log in instead",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"backend-go/config"
 	"backend-go/database/redisx"
 	rdsModel "backend-go/models/redis"
 	"backend-go/utils"
@@ -35,7 +34,7 @@ func (rl *RateLimiter) AddRouteLimit(path string, cfg rdsModel.RateLimitConfig)
 // Token Bucket Algorithm
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := utils.GetClientIP(r, config.IsLocal())
+		ip := utils.GetClientIP(r)
 		path := r.URL.Path
 		key := "ratelimit:" + ip + ":" + path
 		ctx := context.Background()
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	contextkeys "backend-go/contextKeys"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// RequirePermission only lets requests through whose role grants all of the permissions and whose token carries
+// them as scopes, see RequireScope. Service principals have no role, for them the scopes decide alone. It relies on
+// the claims AuthMiddleware puts into the context, so it has to be wrapped by it:
+//
+//	middleware.AuthMiddleware(middleware.RequirePermission("users:read")(handler), tokenValidator)
+func RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+			if !ok || userContent.Claims == nil {
+				http.Error(w, "Could not get user info", http.StatusUnauthorized)
+				return
+			}
+
+			if !userContent.IsServicePrincipal() {
+				for _, permission := range permissions {
+					if !utils.HasPermission(userContent.Claims.Role, permission) {
+						log.Printf("rbac: user %s with role %q lacks %s for %s", userContent.Claims.UserID, userContent.Claims.Role, permission, r.URL.Path)
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(http.StatusForbidden)
+						json.NewEncoder(w).Encode(map[string]string{
+							"error":   "Forbidden",
+							"message": "Missing permission " + permission,
+						})
+						return
+					}
+				}
+			}
+			if !hasScopes(w, r, userContent, permissions) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
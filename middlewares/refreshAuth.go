@@ -2,18 +2,22 @@
 package middleware
 
 import (
-	"backend-go/config"
+	domainerrors "backend-go/constants/errors"
 	contextkeys "backend-go/contextKeys"
 	redisRepository "backend-go/internal/user/repository/redis"
+	"backend-go/internal/user/services"
 	userType "backend-go/type"
 	"backend-go/utils"
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 )
 
-func RefreshAuthMiddleware(next http.Handler, UserRedisRepo redisRepository.UserRedisRepository) http.Handler {
+// RefreshAuthMiddleware checks the refresh token in the request header through the TokenValidator, the access token cookie
+// it replaces must not be revoked
+func RefreshAuthMiddleware(next http.Handler, tokenValidator services.TokenValidator, UserRedisRepo redisRepository.UserRedisRepository) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		//check if user is blacklisted
 		accessToken, errAccessToken := r.Cookie("access_token")
@@ -36,36 +40,30 @@ func RefreshAuthMiddleware(next http.Handler, UserRedisRepo redisRepository.User
 			return
 		}
 
-		refreshTokenClaims, tokenErr := utils.VerifyAndParseJWTToken(refreshToken)
-		if tokenErr != nil {
-			log.Println("Token from header:", refreshTokenClaims, tokenErr)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		// verify the token, that it is still the current one of its session and the client ip bound to the session
+		refreshTokenClaims, session, err := tokenValidator.ValidateRefreshToken(r.Context(), refreshToken, utils.GetClientIP(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, domainerrors.ErrInvalidToken):
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+			case errors.Is(err, domainerrors.ErrRefreshTokenReused),
+				errors.Is(err, domainerrors.ErrSessionNotFound),
+				errors.Is(err, domainerrors.ErrClientIPMismatch):
+				http.Error(w, "Unauthorized - invalid session", http.StatusUnauthorized)
+			default:
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		//verify token expiry and check ip address
-		unixTimeSeconds := time.Now().Unix()
-		if refreshTokenClaims.ExpiresAt == nil || refreshTokenClaims.ExpiresAt.Unix() < unixTimeSeconds {
-			log.Println("Refresh Token expired")
-			http.Error(w, "Token expired", http.StatusUnauthorized)
-			return
-		}
-
-		storedRdxToken, err := UserRedisRepo.GetToken(r.Context(), refreshTokenClaims.UserID)
-		clientIp := utils.GetClientIP(r, config.IsLocal())
-		if err != nil || storedRdxToken == nil {
-			log.Printf("Failed to get user session from Redis: %v", err)
-			http.Error(w, "Unauthorized - invalid session", http.StatusUnauthorized)
-			return
-		} else if storedRdxToken.IPAddress != clientIp || storedRdxToken.RefreshToken != refreshToken {
-			log.Printf("IP address mismatch: token IP %s, request IP %s", storedRdxToken.IPAddress, clientIp)
-			http.Error(w, "Unauthorized - invalid session", http.StatusUnauthorized)
-			return
+		if _, err := tokenValidator.TouchSession(r.Context(), session.SessionID); err != nil {
+			log.Printf("Failed to update last seen of session %s: %v", session.SessionID, err)
 		}
 
 		userContents := userType.UserContents{
-			Claims:      refreshTokenClaims,
-			AccessToken: accessToken.Value,
+			Claims:       refreshTokenClaims,
+			AccessToken:  accessToken.Value,
+			RefreshToken: refreshToken,
 		}
 		ctx := context.WithValue(r.Context(), contextkeys.UserKey, userContents)
 
@@ -74,13 +72,17 @@ func RefreshAuthMiddleware(next http.Handler, UserRedisRepo redisRepository.User
 }
 
 func checkIsUserBlacklist(r *http.Request, UserRedisRepo redisRepository.UserRedisRepository, accessToken string) (bool, error) {
-	accessTokenClaims, tokenErr := utils.VerifyAndParseJWTToken(accessToken)
+	accessTokenClaims, tokenErr := utils.VerifyJWTTokenIgnoringExpiry(accessToken, utils.AccessTokenType)
 	if tokenErr != nil {
 		log.Println("Invalid token", tokenErr)
 		return false, tokenErr
 	}
+	if accessTokenClaims.ExpiresAt == nil || !accessTokenClaims.ExpiresAt.After(time.Now()) {
+		// denylist entries expire together with the token, an expired access token has nothing left to check
+		return true, nil
+	}
 
-	isBlacklisted, err := UserRedisRepo.IsBlacklistedAccessToken(r.Context(), accessTokenClaims.UserID, accessToken)
+	isBlacklisted, err := UserRedisRepo.IsBlacklistedAccessToken(r.Context(), accessTokenClaims.ID)
 	if err != nil || isBlacklisted {
 		return false, err
 	}
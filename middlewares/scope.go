@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	contextkeys "backend-go/contextKeys"
+	userType "backend-go/type"
+	"backend-go/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RequireScope only lets requests through whose token carries all of the scopes. Access tokens get their scope at login,
+// API keys and service tokens at creation. It relies on the claims AuthMiddleware puts into the context and, being a
+// mux.MiddlewareFunc, can guard a single route or a whole subrouter:
+//
+//	r.Handle("/users", middleware.AuthMiddleware(middleware.RequireScope("users:read")(handler), tokenValidator))
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userContent, ok := r.Context().Value(contextkeys.UserKey).(userType.UserContents)
+			if !ok || userContent.Claims == nil {
+				http.Error(w, "Could not get user info", http.StatusUnauthorized)
+				return
+			}
+			if !hasScopes(w, r, userContent, scopes) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasScopes checks the scopes of the token and answers with insufficient_scope (RFC 6750 section 3.1) if one is missing
+func hasScopes(w http.ResponseWriter, r *http.Request, userContent userType.UserContents, scopes []string) bool {
+	granted := userContent.Claims.Scopes()
+	for _, scope := range scopes {
+		if utils.HasScope(granted, scope) {
+			continue
+		}
+
+		log.Printf("scope: token of %s lacks scope %s for %s", userContent.Claims.Subject, scope, r.URL.Path)
+		required := strings.Join(scopes, " ")
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", error_description="the token is missing scope %s", scope="%s"`, scope, required))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             "insufficient_scope",
+			"error_description": "the token is missing scope " + scope,
+			"scope":             required,
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,15 @@
+package model
+
+// APIKey is a personal access token of a user for scripts and CI. Only the sha256 of the key is stored,
+// the key itself is shown once when it is created.
+type APIKey struct {
+	ID         string   `bson:"_id,omitempty" json:"id"`
+	UserID     string   `bson:"user_id" json:"-"`
+	Name       string   `bson:"name" json:"name"`
+	Prefix     string   `bson:"prefix" json:"prefix"` // start of the key, lets users recognise it in the list
+	KeyHash    string   `bson:"key_hash" json:"-"`
+	Scopes     []string `bson:"scopes" json:"scopes"` // permissions the key is limited to, within those of the user's role
+	CreatedAt  int64    `bson:"created_at" json:"created_at"`
+	ExpiresAt  int64    `bson:"expires_at" json:"expires_at"`
+	LastUsedAt int64    `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// OAuthClient is a registered client. Only the SHA-256 hash of its secret is stored.
+// Public clients (SPAs, mobile apps) have no secret and must use PKCE.
+// Confidential clients listing client_credentials in their grant types act as a service principal limited to their scopes.
+type OAuthClient struct {
+	ClientID     string   `bson:"client_id" json:"client_id"`
+	Name         string   `bson:"name" json:"name"`
+	SecretHash   string   `bson:"secret_hash" json:"secret_hash"`
+	Public       bool     `bson:"public" json:"public"`
+	RedirectURIs []string `bson:"redirect_uris" json:"redirect_uris"`
+	GrantTypes   []string `bson:"grant_types" json:"grant_types"` // defaults to authorization_code
+	Scopes       []string `bson:"scopes" json:"scopes"`           // permissions the client may request for itself
+}
@@ -0,0 +1,15 @@
+package rdsModel
+
+// AuthorizationCode is what an OIDC authorization code stands for until the client redeems it
+type AuthorizationCode struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	SessionID     string `json:"session_id"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce"`
+	CodeChallenge string `json:"code_challenge"`
+	AuthTime      int64  `json:"auth_time"` // unix seconds the user logged in
+}
@@ -0,0 +1,8 @@
+package rdsModel
+
+// OIDCLoginState is a login through an external provider waiting for its callback
+type OIDCLoginState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
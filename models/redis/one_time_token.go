@@ -0,0 +1,10 @@
+package rdsModel
+
+// OneTimeToken is what an emailed single use token (password reset, verification, magic link) stands for
+type OneTimeToken struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package rdsModel
+
+// Session is one logged-in device of a user. The refresh token issued at login is bound to it.
+type Session struct {
+	SessionID    string `redis:"sessionId" json:"session_id"`
+	UserID       string `redis:"userId" json:"user_id"`
+	RefreshToken string `redis:"refreshToken" json:"-"`
+	IPAddress    string `redis:"ipAddress" json:"ip_address"`
+	UserAgent    string `redis:"userAgent" json:"user_agent"`
+	DeviceLabel  string `redis:"deviceLabel" json:"device_label"`
+	Scope        string `redis:"scope" json:"scope"`             // granted at login, every access token of the session carries it
+	CreatedAt    int64  `redis:"createdAt" json:"created_at"`    // unix seconds
+	LastSeenAt   int64  `redis:"lastSeenAt" json:"last_seen_at"` // unix seconds
+}
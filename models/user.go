@@ -7,4 +7,14 @@ type User struct {
 	Role      string `bson:"role" json:"role"`
 	Token     string `bson:"token" json:"token"`
 	IPAddress string `bson:"ip_address"`
+
+	EmailVerified bool `bson:"email_verified" json:"email_verified"`
+	Disabled      bool `bson:"disabled" json:"disabled"` // set by admins, disabled accounts can not log in
+
+	// two factor authentication, the TOTP secrets are encrypted with utils.EncryptSecret
+	MFAEnabled        bool     `bson:"mfa_enabled" json:"mfa_enabled"`
+	TOTPSecret        string   `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret string   `bson:"totp_pending_secret,omitempty" json:"-"` // enrolled but not confirmed yet
+	TOTPLastStep      int64    `bson:"totp_last_step,omitempty" json:"-"`      // last accepted time step, codes are single use
+	RecoveryCodes     []string `bson:"recovery_codes,omitempty" json:"-"`      // sha256 of the unused recovery codes
 }
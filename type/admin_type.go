@@ -0,0 +1,40 @@
+package userType
+
+import model "backend-go/models"
+
+// UserFilter narrows the admin user listing, empty fields do not filter
+type UserFilter struct {
+	Email    string // case insensitive substring
+	Role     string
+	Disabled *bool
+}
+
+// AdminUserView is what admins see of an account, secrets and tokens are left out
+type AdminUserView struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	MFAEnabled    bool   `json:"mfa_enabled"`
+	Disabled      bool   `json:"disabled"`
+	LastIPAddress string `json:"last_ip_address"`
+}
+
+type UserPage struct {
+	Users []AdminUserView `json:"users"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+	Total int64           `json:"total"`
+}
+
+func NewAdminUserView(user model.User) AdminUserView {
+	return AdminUserView{
+		ID:            user.ID,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.MFAEnabled,
+		Disabled:      user.Disabled,
+		LastIPAddress: user.IPAddress,
+	}
+}
@@ -2,6 +2,7 @@ package userType
 
 import (
 	model "backend-go/models"
+	rdsModel "backend-go/models/redis"
 	"backend-go/utils"
 )
 
@@ -9,9 +10,71 @@ type UserResponse struct {
 	User         *model.User
 	AccessToken  string
 	RefreshToken string
+	SessionID    string
+	MFAToken     string // set instead of the token pair when the login still needs a second factor
 }
 
 type UserContents struct {
-	Claims      *utils.Claims
-	AccessToken string
+	Claims       *utils.Claims
+	AccessToken  string
+	RefreshToken string        // only set on routes behind RefreshAuthMiddleware
+	APIKey       *model.APIKey // set instead of AccessToken when the request is authenticated with an API key
+}
+
+// IsServicePrincipal reports whether the request was made by an OAuth client on its own behalf (client credentials grant).
+// Its claims carry the client id and scopes but no user.
+func (c UserContents) IsServicePrincipal() bool {
+	return c.Claims != nil && c.Claims.TokenType == utils.ServiceTokenType
+}
+
+type LoginRequest struct {
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	DeviceLabel string `json:"device_label"`
+	Scope       string `json:"scope"`
+}
+
+// MFARequest completes a login with either a TOTP code or a recovery code
+type MFARequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	DeviceLabel  string `json:"device_label"`
+}
+
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// ClientInfo describes the device a session is created from
+type ClientInfo struct {
+	IPAddress   string
+	UserAgent   string
+	DeviceLabel string
+	Scope       string // space separated scopes asked for at login, empty for everything the role grants
+}
+
+type SessionInfo struct {
+	rdsModel.Session
+	Current bool `json:"current"`
+}
+
+// FieldError tells the client which field of the request is invalid and why
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type APIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// CreatedAPIKey is the only response that contains the key itself
+type CreatedAPIKey struct {
+	Key    string        `json:"key"`
+	APIKey *model.APIKey `json:"api_key"`
 }
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ASNDatabase maps addresses to the autonomous system announcing them. It reads the tab separated ip2asn files
+// of iptoasn.com (ip2asn-combined.tsv): "<range start>\t<range end>\t<AS number>\t<country>\t<description>".
+// Ranges announced by no AS have the number 0 and are skipped.
+type ASNDatabase struct {
+	ranges []asnRange // sorted by start, not overlapping
+}
+
+type asnRange struct {
+	start netip.Addr
+	end   netip.Addr
+	asn   uint32
+}
+
+func LoadASNDatabaseFile(path string) (*ASNDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading asn database: %w", err)
+	}
+	defer file.Close()
+
+	return ParseASNDatabase(file)
+}
+
+func ParseASNDatabase(r io.Reader) (*ASNDatabase, error) {
+	db := &ASNDatabase{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("asn database line %d: expected start, end and AS number", line)
+		}
+		start, errStart := netip.ParseAddr(fields[0])
+		end, errEnd := netip.ParseAddr(fields[1])
+		asn, errASN := strconv.ParseUint(fields[2], 10, 32)
+		if errStart != nil || errEnd != nil || errASN != nil || start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("asn database line %d: invalid range", line)
+		}
+		if asn == 0 {
+			continue
+		}
+		db.ranges = append(db.ranges, asnRange{start: start.Unmap(), end: end.Unmap(), asn: uint32(asn)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading asn database: %w", err)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+// Lookup returns the AS number announcing the address
+func (db *ASNDatabase) Lookup(addr netip.Addr) (uint32, bool) {
+	addr = addr.Unmap()
+
+	// the last range starting at or before the address
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) || db.ranges[i].start.Is4() != addr.Is4() {
+		return 0, false
+	}
+	return db.ranges[i].asn, true
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswords checks passwords against a local copy of the Have I Been Pwned range files. The directory holds one file
+// per 5 character SHA-1 prefix (e.g. 21BD1 or 21BD1.txt) with lines of "<remaining 35 characters>:<count>", so a lookup
+// only reads the small file of its prefix (k-anonymity).
+type BreachedPasswords struct {
+	Dir string
+	// a password counts as breached once it was seen at least this often
+	MinCount int
+}
+
+const breachedPrefixLength = 5
+
+// IsBreached reports whether the password is in the dataset. A missing range file means the prefix is not in the dataset.
+func (b *BreachedPasswords) IsBreached(password string) (bool, error) {
+	if b == nil || b.Dir == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+
+	file, err := b.openRange(prefix)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	minCount := b.MinCount
+	if minCount < 1 {
+		minCount = 1
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, countText, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !found || !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+		count, err := strconv.Atoi(countText)
+		if err != nil {
+			return false, err
+		}
+		return count >= minCount, nil
+	}
+
+	return false, scanner.Err()
+}
+
+func (b *BreachedPasswords) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(b.Dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+	return file, err
+}
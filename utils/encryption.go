@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const encryptionVersion = "v1"
+
+var encryptionKey []byte
+
+var ErrEncryptionKeyMissing = errors.New("encryption key is not configured")
+
+// InitEncryptionKey loads the AES-256 key used for secrets stored at rest (base64 of 32 bytes in DATA_ENCRYPTION_KEY).
+// Without a key the server starts, but features storing encrypted secrets are unavailable.
+func InitEncryptionKey() error {
+	// read directly, config.GetEnv echoes values to stdout
+	encoded, _ := os.LookupEnv("DATA_ENCRYPTION_KEY")
+	if encoded == "" {
+		encryptionKey = nil
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decoding DATA_ENCRYPTION_KEY: %w", err)
+	}
+	return SetEncryptionKey(key)
+}
+
+func SetEncryptionKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	encryptionKey = key
+	return nil
+}
+
+// EncryptSecret seals the plaintext with AES-256-GCM, the result is "v1:" followed by base64 of nonce and ciphertext
+func EncryptSecret(plaintext string) (string, error) {
+	aead, err := newAEAD()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return encryptionVersion + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(ciphertext string) (string, error) {
+	aead, err := newAEAD()
+	if err != nil {
+		return "", err
+	}
+
+	version, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok || version != encryptionVersion {
+		return "", fmt.Errorf("unsupported ciphertext format")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func newAEAD() (cipher.AEAD, error) {
+	if encryptionKey == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
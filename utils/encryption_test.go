@@ -0,0 +1,50 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptSecret_RoundTrip(t *testing.T) {
+	require.NoError(t, utils.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)))
+
+	ciphertext, err := utils.EncryptSecret("my totp secret")
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "my totp secret")
+
+	again, err := utils.EncryptSecret("my totp secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again, "every encryption uses a fresh nonce")
+
+	plaintext, err := utils.DecryptSecret(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "my totp secret", plaintext)
+}
+
+func TestDecryptSecret_RejectsTamperingAndOtherKeys(t *testing.T) {
+	require.NoError(t, utils.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)))
+	ciphertext, err := utils.EncryptSecret("my totp secret")
+	require.NoError(t, err)
+
+	tampered := []byte(ciphertext)
+	last := len(tampered) - 2
+	if tampered[last] == 'A' {
+		tampered[last] = 'B'
+	} else {
+		tampered[last] = 'A'
+	}
+	_, err = utils.DecryptSecret(string(tampered))
+	assert.Error(t, err)
+
+	require.NoError(t, utils.SetEncryptionKey(bytes.Repeat([]byte{8}, 32)))
+	_, err = utils.DecryptSecret(ciphertext)
+	assert.Error(t, err)
+}
+
+func TestSetEncryptionKey_RequiresAES256(t *testing.T) {
+	assert.Error(t, utils.SetEncryptionKey([]byte("too short")))
+}
@@ -0,0 +1,20 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashToken(t *testing.T) {
+	hash := utils.HashToken("secret")
+	assert.Equal(t, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", hash)
+	assert.NotEqual(t, hash, utils.HashToken("Secret"))
+}
+
+func TestSecureCompare(t *testing.T) {
+	assert.True(t, utils.SecureCompare("abc", "abc"))
+	assert.False(t, utils.SecureCompare("abc", "abd"))
+	assert.False(t, utils.SecureCompare("abc", "abcd"))
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"backend-go/constants"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token
+type IDTokenClaims struct {
+	Email    string `json:"email,omitempty"`
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenSigningKey returns the active signing key if relying parties can verify ID tokens signed with it.
+// An HMAC key would have to be shared with every client, and the JWKS does not publish it.
+func IDTokenSigningKey() (*SigningKey, error) {
+	key, err := ActiveSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if !key.Asymmetric() {
+		return nil, fmt.Errorf("ID tokens need an asymmetric signing key (RS256, ES256 or EdDSA), the active key %s uses %s", key.KeyID, key.Method.Alg())
+	}
+	return key, nil
+}
+
+// GenerateIDToken issues an ID token for the client the user authorized
+func GenerateIDToken(userID string, email string, clientID string, nonce string, authTime time.Time) (string, error) {
+	key, err := IDTokenSigningKey()
+	if err != nil {
+		return "", err
+	}
+	jti, err := GenerateSecureToken(constants.JWT_ID_BYTES)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &IDTokenClaims{
+		Email:    email,
+		Nonce:    nonce,
+		AuthTime: authTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   userID,
+			Issuer:    constants.OIDC_ISSUER,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(constants.ID_TOKEN_EXPIRATION)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signTokenWith(key, claims)
+}
@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"backend-go/constants"
+	"backend-go/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIDToken(t *testing.T) {
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+	key := newECSigningKey(t, "oidc")
+	utils.SetSigningKey(key)
+
+	authTime := time.Now().Add(-time.Minute)
+	token, err := utils.GenerateIDToken("user123", "test@gmail.com", "web-app", "n-0S6_WzA2Mj", authTime)
+	require.NoError(t, err)
+
+	claims := &utils.IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return key.VerifyKey, nil
+	}, jwt.WithAudience("web-app"), jwt.WithIssuer(constants.OIDC_ISSUER))
+	require.NoError(t, err)
+
+	assert.Equal(t, "user123", claims.Subject)
+	assert.Equal(t, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.Equal(t, authTime.Unix(), claims.AuthTime)
+	assert.Equal(t, "oidc", tokenKeyID(t, token))
+}
+
+func TestGenerateIDToken_RefusesHMACKey(t *testing.T) {
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+	utils.SetSigningKey(utils.NewHMACSigningKey("hs256", []byte("secret")))
+
+	_, err := utils.GenerateIDToken("user123", "test@gmail.com", "web-app", "", time.Now())
+	assert.Error(t, err, "relying parties can not verify an ID token signed with a shared secret")
+}
@@ -1,39 +1,200 @@
 package utils
 
 import (
+	"backend-go/config"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 )
 
-func GetClientIP(r *http.Request, isLocal bool) string {
-	if isLocal {
-		return "127.0.0.1"
+// Forwarding headers the trusted proxies can be configured to set, only the configured one is read
+const (
+	ProxyHeaderXForwardedFor = "x-forwarded-for"
+	ProxyHeaderForwarded     = "forwarded"
+	ProxyHeaderXRealIP       = "x-real-ip"
+)
+
+// TrustedProxies are the reverse proxies and load balancers whose forwarding header is believed.
+// Requests from any other address are taken to come straight from the client, whatever headers they carry.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+	header   string
+}
+
+var (
+	trustedProxiesMu sync.RWMutex
+	trustedProxies   = &TrustedProxies{}
+)
+
+// ParseTrustedProxies reads a comma separated list of CIDRs or single addresses, e.g. "10.0.0.0/8, 127.0.0.1, ::1",
+// and the header the proxies set, one of the ProxyHeader constants. An empty header means X-Forwarded-For.
+func ParseTrustedProxies(list string, header string) (*TrustedProxies, error) {
+	header = strings.ToLower(strings.TrimSpace(header))
+	switch header {
+	case "":
+		header = ProxyHeaderXForwardedFor
+	case ProxyHeaderXForwardedFor, ProxyHeaderForwarded, ProxyHeaderXRealIP:
+	default:
+		return nil, fmt.Errorf("trusted proxy header %q: must be %s, %s or %s", header, ProxyHeaderXForwardedFor, ProxyHeaderForwarded, ProxyHeaderXRealIP)
 	}
 
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		for _, ip := range ips {
-			ip = strings.TrimSpace(ip)
-			if ip != "" {
-				return ip
+	proxies := &TrustedProxies{header: header}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+			}
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
 			}
+			proxies.prefixes = append(proxies.prefixes, prefix.Masked())
+			continue
 		}
-	}
 
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		proxies.prefixes = append(proxies.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
 	}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	return proxies, nil
+}
+
+// InitTrustedProxies loads the proxies configured in TRUSTED_PROXIES and the header they set from TRUSTED_PROXY_HEADER,
+// without proxies no forwarding header is believed
+func InitTrustedProxies() error {
+	proxies, err := ParseTrustedProxies(config.GetEnv("TRUSTED_PROXIES", ""), config.GetEnv("TRUSTED_PROXY_HEADER", ProxyHeaderXForwardedFor))
 	if err != nil {
-		log.Printf("Failed to get client IP: %v", err)
+		return err
+	}
+	SetTrustedProxies(proxies)
+	return nil
+}
+
+func SetTrustedProxies(proxies *TrustedProxies) {
+	trustedProxiesMu.Lock()
+	defer trustedProxiesMu.Unlock()
+	trustedProxies = proxies
+}
+
+func CurrentTrustedProxies() *TrustedProxies {
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	return trustedProxies
+}
+
+func (p *TrustedProxies) Contains(addr netip.Addr) bool {
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP resolves the address of the client. The configured forwarding header is only read when the request comes
+// from a trusted proxy, the other headers are never read: a proxy only appends to its own header and passes the others
+// on as the client sent them. X-Forwarded-For and Forwarded hops are walked from the right, the nearest one, and the
+// first address that is not a trusted proxy is the client. Everything left of it could have been made up by the client.
+// X-Real-IP is replaced by the proxy and taken as it is. Addresses are returned normalised, IPv4-mapped IPv6 addresses
+// as plain IPv4.
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	remote, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		log.Printf("Failed to get client IP from remote address %q", r.RemoteAddr)
 		return r.RemoteAddr
 	}
+	if !p.Contains(remote) {
+		return remote.String()
+	}
 
-	//improvment : can add fallback value also
-	return ip
+	var hops []string
+	switch p.header {
+	case ProxyHeaderForwarded:
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	case ProxyHeaderXRealIP:
+		if realIP, ok := parseHop(r.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+	default:
+		hops = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	if len(hops) == 0 {
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// "unknown" or an obfuscated identifier, the trusted proxy that reported it is the best we know
+			break
+		}
+		client = hop
+		if !p.Contains(hop) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// GetClientIP resolves the client address behind the configured trusted proxies
+func GetClientIP(r *http.Request) string {
+	return CurrentTrustedProxies().ClientIP(r)
+}
+
+// forwardedFor collects the for= parameters of all Forwarded headers in order (RFC 7239 section 4)
+func forwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+func xForwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop accepts an address with or without port, IPv6 optionally in brackets ("[2001:db8::1]:4711")
+func parseHop(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
 }
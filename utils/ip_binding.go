@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"backend-go/config"
+	"fmt"
+	"net/netip"
+	"sync"
+)
+
+// IPBindingMode decides how close the address of a request has to be to the one a session was opened from
+type IPBindingMode string
+
+const (
+	IPBindingStrict   IPBindingMode = "strict"   // the exact address
+	IPBindingSubnet   IPBindingMode = "subnet"   // the same IPv4 or IPv6 network, /24 and /64 by default
+	IPBindingASN      IPBindingMode = "asn"      // the same autonomous system, e.g. a mobile carrier
+	IPBindingDisabled IPBindingMode = "disabled" // any address, changes are only logged
+)
+
+// IPBindingPolicy is the one place session ip binding is decided, the token validator and magic link logins ask it
+type IPBindingPolicy struct {
+	Mode             IPBindingMode
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+	ASNs             *ASNDatabase // required by IPBindingASN
+}
+
+var (
+	ipBindingPolicyMu sync.RWMutex
+	ipBindingPolicy   = DefaultIPBindingPolicy()
+)
+
+// DefaultIPBindingPolicy is strict, like the binding was before it could be configured
+func DefaultIPBindingPolicy() *IPBindingPolicy {
+	return &IPBindingPolicy{
+		Mode:             IPBindingStrict,
+		IPv4PrefixLength: 24,
+		IPv6PrefixLength: 64,
+	}
+}
+
+// InitIPBindingPolicy loads the policy configured in the environment
+func InitIPBindingPolicy() error {
+	policy := &IPBindingPolicy{
+		Mode:             IPBindingMode(config.GetEnv("SESSION_IP_BINDING", string(IPBindingStrict))),
+		IPv4PrefixLength: config.GetEnvInt("SESSION_IP_BINDING_IPV4_PREFIX", 24),
+		IPv6PrefixLength: config.GetEnvInt("SESSION_IP_BINDING_IPV6_PREFIX", 64),
+	}
+
+	switch policy.Mode {
+	case IPBindingStrict, IPBindingSubnet, IPBindingDisabled:
+	case IPBindingASN:
+		path := config.GetEnv("ASN_DATABASE_FILE", "")
+		if path == "" {
+			return fmt.Errorf("SESSION_IP_BINDING=asn needs ASN_DATABASE_FILE")
+		}
+		asns, err := LoadASNDatabaseFile(path)
+		if err != nil {
+			return err
+		}
+		policy.ASNs = asns
+	default:
+		return fmt.Errorf("unknown SESSION_IP_BINDING %q, use strict, subnet, asn or disabled", policy.Mode)
+	}
+	if policy.IPv4PrefixLength < 0 || policy.IPv4PrefixLength > 32 || policy.IPv6PrefixLength < 0 || policy.IPv6PrefixLength > 128 {
+		return fmt.Errorf("session ip binding prefix lengths must be 0-32 for IPv4 and 0-128 for IPv6")
+	}
+
+	SetIPBindingPolicy(policy)
+	return nil
+}
+
+func SetIPBindingPolicy(policy *IPBindingPolicy) {
+	ipBindingPolicyMu.Lock()
+	defer ipBindingPolicyMu.Unlock()
+	ipBindingPolicy = policy
+}
+
+func CurrentIPBindingPolicy() *IPBindingPolicy {
+	ipBindingPolicyMu.RLock()
+	defer ipBindingPolicyMu.RUnlock()
+	return ipBindingPolicy
+}
+
+// Allows reports whether a request from requestIP may use a session opened from sessionIP.
+// In ASN mode addresses missing from the database fall back to the subnet check.
+func (p *IPBindingPolicy) Allows(sessionIP string, requestIP string) bool {
+	if sessionIP == requestIP {
+		return true
+	}
+	if p.Mode == IPBindingDisabled {
+		LogSecurityEvent("session_ip_changed", "session bound to %s used from %s", sessionIP, requestIP)
+		return true
+	}
+
+	bound, errBound := netip.ParseAddr(sessionIP)
+	current, errCurrent := netip.ParseAddr(requestIP)
+	if errBound != nil || errCurrent != nil {
+		return false
+	}
+	bound, current = bound.Unmap(), current.Unmap()
+
+	switch p.Mode {
+	case IPBindingSubnet:
+		return p.sameSubnet(bound, current)
+	case IPBindingASN:
+		if p.ASNs != nil {
+			boundASN, okBound := p.ASNs.Lookup(bound)
+			currentASN, okCurrent := p.ASNs.Lookup(current)
+			if okBound && okCurrent {
+				return boundASN == currentASN
+			}
+		}
+		return p.sameSubnet(bound, current)
+	default:
+		return bound == current
+	}
+}
+
+func (p *IPBindingPolicy) sameSubnet(a netip.Addr, b netip.Addr) bool {
+	if a.Is4() != b.Is4() {
+		return false
+	}
+
+	bits := p.IPv6PrefixLength
+	if a.Is4() {
+		bits = p.IPv4PrefixLength
+	}
+	prefixA, errA := a.Prefix(bits)
+	prefixB, errB := b.Prefix(bits)
+	return errA == nil && errB == nil && prefixA == prefixB
+}
+
+// SessionIPAllowed asks the configured policy
+func SessionIPAllowed(sessionIP string, requestIP string) bool {
+	return CurrentIPBindingPolicy().Allows(sessionIP, requestIP)
+}
@@ -0,0 +1,79 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testASNDatabase = `1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+10.0.0.0	10.0.255.255	64500	ZZ	EXAMPLE-MOBILE
+10.1.0.0	10.1.255.255	64501	ZZ	EXAMPLE-HOME
+10.2.0.0	10.2.255.255	0	None	Not routed
+2001:db8::	2001:db8:ffff:ffff:ffff:ffff:ffff:ffff	64500	ZZ	EXAMPLE-MOBILE
+`
+
+func TestIPBindingPolicy_Strict(t *testing.T) {
+	policy := utils.DefaultIPBindingPolicy()
+
+	assert.True(t, policy.Allows("203.0.113.7", "203.0.113.7"))
+	assert.False(t, policy.Allows("203.0.113.7", "203.0.113.8"))
+	assert.True(t, policy.Allows("203.0.113.7", "::ffff:203.0.113.7"), "IPv4-mapped addresses are the same address")
+	assert.False(t, policy.Allows("", "203.0.113.7"))
+}
+
+func TestIPBindingPolicy_Subnet(t *testing.T) {
+	policy := utils.DefaultIPBindingPolicy()
+	policy.Mode = utils.IPBindingSubnet
+
+	assert.True(t, policy.Allows("203.0.113.7", "203.0.113.200"))
+	assert.False(t, policy.Allows("203.0.113.7", "203.0.114.7"))
+	assert.True(t, policy.Allows("2001:db8:1:2::1", "2001:db8:1:2:abcd::9"), "same /64")
+	assert.False(t, policy.Allows("2001:db8:1:2::1", "2001:db8:1:3::1"))
+	assert.False(t, policy.Allows("203.0.113.7", "2001:db8:1:2::1"))
+	assert.False(t, policy.Allows("203.0.113.7", "not-an-ip"))
+}
+
+func TestIPBindingPolicy_ASN(t *testing.T) {
+	asns, err := utils.ParseASNDatabase(strings.NewReader(testASNDatabase))
+	require.NoError(t, err)
+	policy := utils.DefaultIPBindingPolicy()
+	policy.Mode = utils.IPBindingASN
+	policy.ASNs = asns
+
+	assert.True(t, policy.Allows("10.0.1.1", "10.0.200.9"), "same carrier, different network")
+	assert.True(t, policy.Allows("10.0.1.1", "2001:db8::5"), "the carrier announces both address families")
+	assert.False(t, policy.Allows("10.0.1.1", "10.1.1.1"))
+	assert.True(t, policy.Allows("10.2.0.1", "10.2.0.99"), "unannounced addresses fall back to the subnet check")
+	assert.False(t, policy.Allows("10.2.0.1", "10.2.1.1"))
+}
+
+func TestIPBindingPolicy_Disabled(t *testing.T) {
+	policy := utils.DefaultIPBindingPolicy()
+	policy.Mode = utils.IPBindingDisabled
+
+	assert.True(t, policy.Allows("203.0.113.7", "198.51.100.1"))
+}
+
+func TestASNDatabase_Lookup(t *testing.T) {
+	asns, err := utils.ParseASNDatabase(strings.NewReader(testASNDatabase))
+	require.NoError(t, err)
+
+	asn, ok := asns.Lookup(netip.MustParseAddr("1.0.0.1"))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(13335), asn)
+
+	_, ok = asns.Lookup(netip.MustParseAddr("1.0.1.0"))
+	assert.False(t, ok)
+	_, ok = asns.Lookup(netip.MustParseAddr("10.2.0.1"))
+	assert.False(t, ok, "AS 0 means not routed")
+	_, ok = asns.Lookup(netip.MustParseAddr("0.0.0.1"))
+	assert.False(t, ok)
+
+	_, err = utils.ParseASNDatabase(strings.NewReader("1.0.0.255\t1.0.0.0\t13335\n"))
+	assert.Error(t, err, "range ends before it starts")
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"backend-go/constants"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyRing holds every key the service knows about. Exactly one key signs at any point in time,
+// the others only verify. A retired key keeps verifying until the tokens it signed have expired.
+type KeyRing struct {
+	keys []*SigningKey
+}
+
+// KeyRingFile is the JSON format of JWT_KEYRING_FILE
+type KeyRingFile struct {
+	Keys []KeyRingEntry `json:"keys"`
+}
+
+// KeyRingEntry configures one key. Asymmetric keys are read from PEM files, HMAC secrets from the named env variable.
+// An entry with only a public key file is verification-only.
+type KeyRingEntry struct {
+	KeyID          string    `json:"kid"`
+	Algorithm      string    `json:"alg"`
+	PrivateKeyFile string    `json:"private_key_file,omitempty"`
+	PublicKeyFile  string    `json:"public_key_file,omitempty"`
+	SecretEnv      string    `json:"secret_env,omitempty"`
+	ActivatesAt    time.Time `json:"activates_at,omitempty"`
+	RetiresAt      time.Time `json:"retires_at,omitempty"`
+}
+
+var (
+	keyRingMu sync.RWMutex
+	keyRing   = &KeyRing{keys: []*SigningKey{NewHMACSigningKey("hs256", JWT_SECRET_KEY)}}
+)
+
+func NewKeyRing(keys ...*SigningKey) (*KeyRing, error) {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.KeyID == "" {
+			return nil, fmt.Errorf("every key in the key ring needs a kid")
+		}
+		if seen[key.KeyID] {
+			return nil, fmt.Errorf("duplicate kid in key ring: %s", key.KeyID)
+		}
+		seen[key.KeyID] = true
+
+		if !key.RetiresAt.IsZero() && !key.ActivatesAt.IsZero() && !key.ActivatesAt.Before(key.RetiresAt) {
+			return nil, fmt.Errorf("key %s retires before it activates", key.KeyID)
+		}
+	}
+
+	return &KeyRing{keys: keys}, nil
+}
+
+// LoadKeyRingFile reads the key ring configuration and all the keys it references
+func LoadKeyRingFile(path string) (*KeyRing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt key ring: %w", err)
+	}
+
+	var file KeyRingFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing jwt key ring: %w", err)
+	}
+
+	keys := make([]*SigningKey, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		key, err := loadKeyRingEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.KeyID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyRing(keys...)
+}
+
+func loadKeyRingEntry(entry KeyRingEntry) (*SigningKey, error) {
+	var key *SigningKey
+
+	switch {
+	case entry.Algorithm == jwt.SigningMethodHS256.Alg():
+		secret := os.Getenv(entry.SecretEnv)
+		if entry.SecretEnv == "" || secret == "" {
+			return nil, fmt.Errorf("secret_env must name a non empty variable")
+		}
+		if err := CheckHMACSecret(secret); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.SecretEnv, err)
+		}
+		key = NewHMACSigningKey(entry.KeyID, []byte(secret))
+	case entry.PrivateKeyFile != "":
+		pemBytes, err := os.ReadFile(entry.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = LoadSigningKeyFromPEM(entry.Algorithm, entry.KeyID, pemBytes); err != nil {
+			return nil, err
+		}
+	case entry.PublicKeyFile != "":
+		pemBytes, err := os.ReadFile(entry.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = LoadVerificationKeyFromPEM(entry.Algorithm, entry.KeyID, pemBytes); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("either private_key_file or public_key_file is required")
+	}
+
+	key.ActivatesAt = entry.ActivatesAt
+	key.RetiresAt = entry.RetiresAt
+
+	return key, nil
+}
+
+// SigningKey returns the key new tokens are signed with: the most recently activated key that can sign and is not retired
+func (k *KeyRing) SigningKey(now time.Time) (*SigningKey, error) {
+	var active *SigningKey
+	for _, key := range k.keys {
+		if key.SignKey == nil || now.Before(key.ActivatesAt) {
+			continue
+		}
+		if !key.RetiresAt.IsZero() && !now.Before(key.RetiresAt) {
+			continue
+		}
+		if active == nil || key.ActivatesAt.After(active.ActivatesAt) {
+			active = key
+		}
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("no active jwt signing key")
+	}
+	return active, nil
+}
+
+// VerificationKey returns the key with the given kid while tokens it signed can still be valid
+func (k *KeyRing) VerificationKey(keyID string, now time.Time) (*SigningKey, bool) {
+	for _, key := range k.keys {
+		if key.KeyID != keyID {
+			continue
+		}
+		if !key.RetiresAt.IsZero() && now.After(key.RetiresAt.Add(constants.JWT_KEY_VERIFICATION_GRACE)) {
+			return nil, false
+		}
+		return key, true
+	}
+
+	return nil, false
+}
+
+// PublicKeys returns the keys verifiers should know about, including keys that are about to be activated
+func (k *KeyRing) PublicKeys(now time.Time) []*SigningKey {
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		if _, ok := k.VerificationKey(key.KeyID, now); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	return keys
+}
+
+func SetKeyRing(ring *KeyRing) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+
+	keyRing = ring
+}
+
+func CurrentKeyRing() *KeyRing {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+
+	return keyRing
+}
+
+// SetSigningKey makes the key the only one tokens are signed and verified with
+func SetSigningKey(key *SigningKey) {
+	SetKeyRing(&KeyRing{keys: []*SigningKey{key}})
+}
+
+func ActiveSigningKey() (*SigningKey, error) {
+	return CurrentKeyRing().SigningKey(time.Now())
+}
+
+// verificationKey picks the key a token was signed with by its kid header
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	ring := CurrentKeyRing()
+	now := time.Now()
+
+	var key *SigningKey
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok = ring.VerificationKey(kid, now)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+	} else {
+		// tokens issued before key ids were introduced
+		active, err := ring.SigningKey(now)
+		if err != nil {
+			return nil, err
+		}
+		key = active
+	}
+
+	// the algorithm is bound to the key, never trust the alg header alone
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	// a retired key must not have signed anything after its retirement
+	if claims, ok := token.Claims.(*Claims); ok && !key.RetiresAt.IsZero() {
+		if claims.IssuedAt == nil || !claims.IssuedAt.Before(key.RetiresAt) {
+			return nil, fmt.Errorf("token signed by retired key: %s", key.KeyID)
+		}
+	}
+
+	return key.VerifyKey, nil
+}
+
+// PublicJWKS returns the public keys of all asymmetric keys, HMAC secrets are never published
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range CurrentKeyRing().PublicKeys(time.Now()) {
+		if jwk, ok := publicJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
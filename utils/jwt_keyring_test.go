@@ -0,0 +1,145 @@
+package utils_test
+
+import (
+	"backend-go/constants"
+	"backend-go/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newECSigningKey(t *testing.T, keyID string) *utils.SigningKey {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := utils.LoadSigningKeyFromPEM("ES256", keyID, encodePrivateKeyPEM(t, ecKey))
+	require.NoError(t, err)
+	return key
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &utils.Claims{})
+	require.NoError(t, err)
+	return parsed.Header["kid"].(string)
+}
+
+func TestKeyRing_RotationKeepsOldTokensValid(t *testing.T) {
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+
+	now := time.Now()
+	oldKey := newECSigningKey(t, "old")
+	newKey := newECSigningKey(t, "new")
+	newKey.ActivatesAt = now.Add(time.Hour)
+
+	ring, err := utils.NewKeyRing(oldKey, newKey)
+	require.NoError(t, err)
+	utils.SetKeyRing(ring)
+
+	oldToken, err := utils.GenerateRefreshToken("user123", "test@gmail.com", "user", "session123")
+	require.NoError(t, err)
+	assert.Equal(t, "old", tokenKeyID(t, oldToken), "new key is not active yet")
+	assert.Len(t, utils.PublicJWKS().Keys, 2, "upcoming key should already be published")
+
+	// rotate: the new key takes over and the old one retires right now
+	newKey.ActivatesAt = now.Add(-time.Second)
+	oldKey.RetiresAt = now.Add(time.Second)
+	ring, err = utils.NewKeyRing(oldKey, newKey)
+	require.NoError(t, err)
+	utils.SetKeyRing(ring)
+
+	newToken, err := utils.GenerateAccessToken("user123", "test@gmail.com", "user", "session123", "profile:read")
+	require.NoError(t, err)
+	assert.Equal(t, "new", tokenKeyID(t, newToken))
+
+	claims, err := utils.VerifyAndParseJWTToken(oldToken, utils.RefreshTokenType)
+	assert.NoError(t, err, "token signed by a retiring key should validate until it expires")
+	assert.NotNil(t, claims)
+
+	_, err = utils.VerifyAndParseJWTToken(newToken, utils.AccessTokenType)
+	assert.NoError(t, err)
+}
+
+func TestKeyRing_RetiredKeyStopsVerifying(t *testing.T) {
+	now := time.Now()
+	key := newECSigningKey(t, "retired")
+	key.ActivatesAt = now.Add(-30 * 24 * time.Hour)
+	key.RetiresAt = now.Add(-constants.JWT_KEY_VERIFICATION_GRACE - time.Minute)
+
+	ring, err := utils.NewKeyRing(key)
+	require.NoError(t, err)
+
+	_, ok := ring.VerificationKey("retired", now)
+	assert.False(t, ok, "key past its grace period must not verify anymore")
+	assert.Empty(t, ring.PublicKeys(now))
+
+	_, err = ring.SigningKey(now)
+	assert.Error(t, err, "no key left to sign with")
+}
+
+func TestKeyRing_VerificationOnlyKeyNeverSigns(t *testing.T) {
+	signing := newECSigningKey(t, "signing")
+	verifyOnly := newECSigningKey(t, "verify-only")
+	verifyOnly.SignKey = nil
+	verifyOnly.ActivatesAt = time.Now().Add(-time.Second)
+
+	ring, err := utils.NewKeyRing(signing, verifyOnly)
+	require.NoError(t, err)
+
+	active, err := ring.SigningKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "signing", active.KeyID)
+}
+
+func TestNewKeyRing_RejectsDuplicateKeyIDs(t *testing.T) {
+	_, err := utils.NewKeyRing(newECSigningKey(t, "same"), newECSigningKey(t, "same"))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyRingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	privatePath := filepath.Join(dir, "current.pem")
+	require.NoError(t, os.WriteFile(privatePath, encodePrivateKeyPEM(t, ecKey), 0o600))
+
+	partnerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&partnerKey.PublicKey)
+	require.NoError(t, err)
+	publicPath := filepath.Join(dir, "partner.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	t.Setenv("JWT_SECRET_PREVIOUS", "previous-secret-of-at-least-32-bytes")
+	retiresAt := time.Now().UTC().Format(time.RFC3339)
+	config := fmt.Sprintf(`{"keys": [
+		{"kid": "current", "alg": "ES256", "private_key_file": %q},
+		{"kid": "partner", "alg": "ES256", "public_key_file": %q},
+		{"kid": "legacy", "alg": "HS256", "secret_env": "JWT_SECRET_PREVIOUS", "retires_at": %q}
+	]}`, privatePath, publicPath, retiresAt)
+	configPath := filepath.Join(dir, "keyring.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0o600))
+
+	ring, err := utils.LoadKeyRingFile(configPath)
+	require.NoError(t, err)
+
+	active, err := ring.SigningKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "current", active.KeyID)
+
+	_, ok := ring.VerificationKey("legacy", time.Now())
+	assert.True(t, ok, "retired secret keeps verifying during the grace period")
+	_, ok = ring.VerificationKey("partner", time.Now())
+	assert.True(t, ok)
+}
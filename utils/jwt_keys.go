@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"backend-go/config"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key the service signs or verifies JWTs with
+type SigningKey struct {
+	KeyID       string
+	Method      jwt.SigningMethod
+	SignKey     interface{} // private key, or the shared secret for HMAC. nil for verification-only keys
+	VerifyKey   interface{} // public key, or the shared secret for HMAC
+	ActivatesAt time.Time   // zero means active right away
+	RetiresAt   time.Time   // zero means never retires
+}
+
+// JWK is the JSON Web Key representation of a public key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// MIN_HMAC_SECRET_BYTES is the key size RFC 7518 section 3.2 requires for HS256
+const MIN_HMAC_SECRET_BYTES = 32
+
+// placeholderSecrets are the example values of JWT_SECRET, the server refuses to sign with them
+var placeholderSecrets = []string{"your_secret_key", "your_jwt_secret_key"}
+
+// InitJWTKeys loads the signing keys configured in the environment.
+// JWT_KEYRING_FILE points to a key ring with several keys (see LoadKeyRingFile), otherwise a single key is used:
+// JWT_SIGNING_ALG selects HS256 (default, uses JWT_SECRET) or RS256/ES256/EdDSA with the PEM private key at JWT_PRIVATE_KEY_FILE.
+// Without key files the server does not start unless JWT_SECRET is set to a real secret.
+func InitJWTKeys() error {
+	if path := config.GetEnv("JWT_KEYRING_FILE", ""); path != "" {
+		ring, err := LoadKeyRingFile(path)
+		if err != nil {
+			return err
+		}
+		SetKeyRing(ring)
+		return nil
+	}
+
+	alg := config.GetEnv("JWT_SIGNING_ALG", "HS256")
+	if alg == jwt.SigningMethodHS256.Alg() {
+		// read directly, config.GetEnv echoes values to stdout
+		secret, _ := os.LookupEnv("JWT_SECRET")
+		if err := CheckHMACSecret(secret); err != nil {
+			return fmt.Errorf("JWT_SECRET: %w, or configure JWT_PRIVATE_KEY_FILE or JWT_KEYRING_FILE", err)
+		}
+		SetSigningKey(NewHMACSigningKey("hs256", []byte(secret)))
+		return nil
+	}
+
+	path := config.GetEnv("JWT_PRIVATE_KEY_FILE", "")
+	if path == "" {
+		return fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
+	}
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading jwt private key: %w", err)
+	}
+
+	key, err := LoadSigningKeyFromPEM(alg, config.GetEnv("JWT_KEY_ID", ""), pemBytes)
+	if err != nil {
+		return err
+	}
+	SetSigningKey(key)
+
+	return nil
+}
+
+// CheckHMACSecret refuses empty, example and short secrets
+func CheckHMACSecret(secret string) error {
+	if slices.Contains(placeholderSecrets, secret) {
+		return fmt.Errorf("the example secret can not be used")
+	}
+	if len(secret) < MIN_HMAC_SECRET_BYTES {
+		return fmt.Errorf("the secret must be at least %d bytes", MIN_HMAC_SECRET_BYTES)
+	}
+	return nil
+}
+
+func NewHMACSigningKey(keyID string, secret []byte) *SigningKey {
+	return &SigningKey{
+		KeyID:     keyID,
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}
+}
+
+// Asymmetric reports whether tokens signed with the key can be verified by others through the JWKS
+func (k *SigningKey) Asymmetric() bool {
+	_, isHMAC := k.Method.(*jwt.SigningMethodHMAC)
+	return !isHMAC
+}
+
+// LoadSigningKeyFromPEM parses a private key for the given algorithm.
+// Without a key id the RFC 7638 thumbprint of the public key is used.
+func LoadSigningKeyFromPEM(alg string, keyID string, pemBytes []byte) (*SigningKey, error) {
+	key := &SigningKey{KeyID: keyID}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		if privateKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodES256, privateKey, &privateKey.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA key is not a signer")
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodEdDSA, privateKey, signer.Public()
+	default:
+		return nil, fmt.Errorf("unsupported jwt signing algorithm: %s", alg)
+	}
+
+	if key.KeyID == "" {
+		thumbprint, err := jwkThumbprint(key)
+		if err != nil {
+			return nil, err
+		}
+		key.KeyID = thumbprint
+	}
+
+	return key, nil
+}
+
+// LoadVerificationKeyFromPEM parses a public key that tokens are only verified with, never signed
+func LoadVerificationKeyFromPEM(alg string, keyID string, pemBytes []byte) (*SigningKey, error) {
+	key := &SigningKey{KeyID: keyID}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.VerifyKey = jwt.SigningMethodRS256, publicKey
+	case jwt.SigningMethodES256.Alg():
+		publicKey, err := jwt.ParseECPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		key.Method, key.VerifyKey = jwt.SigningMethodES256, publicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		publicKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.VerifyKey = jwt.SigningMethodEdDSA, publicKey
+	default:
+		return nil, fmt.Errorf("unsupported jwt signing algorithm: %s", alg)
+	}
+
+	if key.KeyID == "" {
+		thumbprint, err := jwkThumbprint(key)
+		if err != nil {
+			return nil, err
+		}
+		key.KeyID = thumbprint
+	}
+
+	return key, nil
+}
+
+func publicJWK(key *SigningKey) (JWK, bool) {
+	jwk := JWK{Use: "sig", Kid: key.KeyID, Alg: key.Method.Alg()}
+
+	switch publicKey := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint from the required members of the JWK
+func jwkThumbprint(key *SigningKey) (string, error) {
+	jwk, ok := publicJWK(key)
+	if !ok {
+		return "", fmt.Errorf("no public key to compute a thumbprint from")
+	}
+
+	var members map[string]string
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case "EC":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X, "y": jwk.Y}
+	default:
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+
+	// encoding/json sorts map keys, which gives the lexicographic order the RFC requires
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,126 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePrivateKeyPEM(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAsymmetricSigningKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		alg        string
+		privateKey interface{}
+		kty        string
+	}{
+		{alg: "RS256", privateKey: rsaKey, kty: "RSA"},
+		{alg: "ES256", privateKey: ecKey, kty: "EC"},
+		{alg: "EdDSA", privateKey: edKey, kty: "OKP"},
+	}
+
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			key, err := utils.LoadSigningKeyFromPEM(tt.alg, "", encodePrivateKeyPEM(t, tt.privateKey))
+			require.NoError(t, err)
+			assert.NotEmpty(t, key.KeyID, "key id should default to the thumbprint")
+			utils.SetSigningKey(key)
+
+			token, err := utils.GenerateAccessToken("user123", "test@gmail.com", "user", "session123", "profile:read")
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &utils.Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, parsed.Header["alg"])
+			assert.Equal(t, key.KeyID, parsed.Header["kid"])
+
+			claims, err := utils.VerifyAndParseJWTToken(token, utils.AccessTokenType)
+			require.NoError(t, err)
+			assert.Equal(t, "user123", claims.UserID)
+
+			var published *utils.JWK
+			for _, jwk := range utils.PublicJWKS().Keys {
+				if jwk.Kid == key.KeyID {
+					published = &jwk
+				}
+			}
+			require.NotNil(t, published, "public key should be published in the JWKS")
+			assert.Equal(t, tt.kty, published.Kty)
+			assert.Equal(t, tt.alg, published.Alg)
+		})
+	}
+}
+
+func TestPublicJWKS_HidesHMACSecret(t *testing.T) {
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+
+	utils.SetSigningKey(utils.NewHMACSigningKey("hmac-test", []byte("secret")))
+	for _, jwk := range utils.PublicJWKS().Keys {
+		assert.NotEqual(t, "hmac-test", jwk.Kid)
+	}
+}
+
+func TestVerifyAndParseJWTToken_UnknownKeyID(t *testing.T) {
+	token, err := utils.GenerateAccessToken("user123", "test@gmail.com", "user", "session123", "profile:read")
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	header := `{"alg":"HS256","kid":"does-not-exist","typ":"JWT"}`
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(header))
+	claims, err := utils.VerifyAndParseJWTToken(strings.Join(parts, "."), utils.AccessTokenType)
+	assert.Error(t, err)
+	assert.Nil(t, claims)
+}
+
+func TestLoadSigningKeyFromPEM_UnsupportedAlgorithm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = utils.LoadSigningKeyFromPEM("ES256", "", encodePrivateKeyPEM(t, ecKey))
+	assert.Error(t, err, "ES256 must reject keys on other curves")
+
+	_, err = utils.LoadSigningKeyFromPEM("none", "", encodePrivateKeyPEM(t, ecKey))
+	assert.Error(t, err)
+}
+
+func TestInitJWTKeys_RefusesWeakSecret(t *testing.T) {
+	previous := utils.CurrentKeyRing()
+	defer utils.SetKeyRing(previous)
+	t.Setenv("JWT_KEYRING_FILE", "")
+	t.Setenv("JWT_SIGNING_ALG", "HS256")
+
+	for _, secret := range []string{"", "your_secret_key", "your_jwt_secret_key", "short"} {
+		t.Setenv("JWT_SECRET", secret)
+		assert.Error(t, utils.InitJWTKeys(), "secret %q", secret)
+	}
+
+	t.Setenv("JWT_SECRET", strings.Repeat("s", utils.MIN_HMAC_SECRET_BYTES))
+	assert.NoError(t, utils.InitJWTKeys())
+}
@@ -1,38 +1,95 @@
 package utils
 
 import (
-	"backend-go/config"
 	"backend-go/constants"
+	"crypto/rand"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type TokenType string
+
+const (
+	AccessTokenType  TokenType = "access"
+	RefreshTokenType TokenType = "refresh"
+	MFATokenType     TokenType = "mfa"     // proves the password step of a login, only redeemable at /login/mfa
+	APIKeyTokenType  TokenType = "api_key" // set on the claims AuthMiddleware builds for a personal API key, never signed
+	ServiceTokenType TokenType = "service" // access token of a client acting on its own behalf (client credentials grant)
+)
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role,omitempty"`
+	SessionID string    `json:"sid"`
+	TokenType TokenType `json:"token_type"`
+	ClientID  string    `json:"client_id,omitempty"` // only set on service tokens
+	Scope     string    `json:"scope,omitempty"`     // space separated permissions the token may be used for
 	jwt.RegisteredClaims
 }
 
-var JWT_SECRET_KEY = []byte(config.GetEnv("JWT_SECRET", "your_secret_key"))
+// Scopes splits the scope claim
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// JWT_SECRET_KEY signs tokens until InitJWTKeys has run. It is random per process, a missing InitJWTKeys never falls
+// back to a secret anyone knows.
+var JWT_SECRET_KEY = randomSecret()
+
+func randomSecret() []byte {
+	secret := make([]byte, MIN_HMAC_SECRET_BYTES)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+func generateJWTToken(userID string, email string, role string, sessionID string, scope string, tokenType TokenType, timeDuration time.Duration) (string, error) {
+	jti, err := GenerateSecureToken(constants.JWT_ID_BYTES)
+	if err != nil {
+		return "", err
+	}
 
-func generateJWTToken(userID string, email string, timeDuration time.Duration) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
+		Scope:     scope,
+		TokenType: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   userID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(timeDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "backend-go",
+			Issuer:    constants.JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{constants.JWT_AUDIENCE},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return signToken(claims)
+}
+
+// signToken signs the claims with the active key of the key ring
+func signToken(claims jwt.Claims) (string, error) {
+	key, err := ActiveSigningKey()
+	if err != nil {
+		return "", err
+	}
+	return signTokenWith(key, claims)
+}
+
+func signTokenWith(key *SigningKey, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KeyID
 
-	tokenString, err := token.SignedString([]byte(JWT_SECRET_KEY))
+	tokenString, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
@@ -40,25 +97,66 @@ func generateJWTToken(userID string, email string, timeDuration time.Duration) (
 	return tokenString, nil
 }
 
-func GenerateAccessToken(userID string, email string) (string, error) {
-	token, err := generateJWTToken(userID, email, constants.ACCESS_TOKEN_EXPIRATION) // 30 minutes
+// GenerateAccessToken issues an access token limited to the scope, see GrantScopes for how it is decided
+func GenerateAccessToken(userID string, email string, role string, sessionID string, scope string) (string, error) {
+	token, err := generateJWTToken(userID, email, role, sessionID, scope, AccessTokenType, constants.ACCESS_TOKEN_EXPIRATION) // 30 minutes
 	return token, err
 }
 
-func GenerateRefreshToken(userID string, email string) (string, error) {
-	token, err := generateJWTToken(userID, email, constants.REFRESH_TOKEN_EXPIRATION) //24 hours
+func GenerateRefreshToken(userID string, email string, role string, sessionID string) (string, error) {
+	token, err := generateJWTToken(userID, email, role, sessionID, "", RefreshTokenType, constants.REFRESH_TOKEN_EXPIRATION) //24 hours
 	return token, err
 }
 
-func VerifyAndParseJWTToken(tokenString string) (*Claims, error) {
+// GenerateMFAToken issues the challenge token of a login waiting for its second factor, it is not bound to a session yet.
+// It carries the scope requested at the password step to the session opened once the second factor is verified.
+func GenerateMFAToken(userID string, email string, scope string) (string, error) {
+	return generateJWTToken(userID, email, "", "", scope, MFATokenType, constants.MFA_TOKEN_EXPIRATION)
+}
+
+// GenerateServiceToken issues the access token of a client credentials grant. The client is the subject, there is no user or session behind it.
+func GenerateServiceToken(clientID string, scope string) (string, error) {
+	jti, err := GenerateSecureToken(constants.JWT_ID_BYTES)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		TokenType: ServiceTokenType,
+		ClientID:  clientID,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   clientID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(constants.SERVICE_TOKEN_EXPIRATION)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    constants.JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{constants.JWT_AUDIENCE},
+		},
+	}
+
+	return signToken(claims)
+}
+
+// UnverifiedTokenType reads the token type without checking the signature.
+// Only use it to pick the validation a token has to go through, never to trust the token.
+func UnverifiedTokenType(tokenString string) TokenType {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return JWT_SECRET_KEY, nil
-	})
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return ""
+	}
+	return claims.TokenType
+}
+
+// VerifyAndParseJWTToken validates the token and rejects it unless it is of the expected type
+func VerifyAndParseJWTToken(tokenString string, expectedType TokenType) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithIssuer(constants.JWT_ISSUER),
+		jwt.WithAudience(constants.JWT_AUDIENCE),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -68,6 +166,32 @@ func VerifyAndParseJWTToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	if claims.TokenType != expectedType {
+		return nil, fmt.Errorf("unexpected token type: %q", claims.TokenType)
+	}
+
+	return claims, nil
+}
+
+// VerifyJWTTokenIgnoringExpiry checks signature, issuer, audience and type like VerifyAndParseJWTToken but accepts
+// an expired token. Only for callers that need to know who an expired token belonged to, it grants nothing.
+func VerifyJWTTokenIgnoringExpiry(tokenString string, expectedType TokenType) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if claims.Issuer != constants.JWT_ISSUER || !slices.Contains(claims.Audience, constants.JWT_AUDIENCE) {
+		return nil, fmt.Errorf("unexpected issuer or audience")
+	}
+	if claims.TokenType != expectedType {
+		return nil, fmt.Errorf("unexpected token type: %q", claims.TokenType)
+	}
+
 	return claims, nil
 }
 
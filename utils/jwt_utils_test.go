@@ -4,12 +4,14 @@ import (
 	"backend-go/utils"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateJWTToken_valid(t *testing.T) {
-	token, err := utils.GenerateAccessToken("user123", "test@gmail.com")
+	token, err := utils.GenerateAccessToken("user123", "test@gmail.com", "user", "session123", "profile:read")
 	assert.NoError(t, err, "GenerateAccessToken failed")
 	assert.NotEmpty(t, token, "GenerateAccessToken returned empty token")
 
@@ -18,7 +20,7 @@ func TestGenerateJWTToken_valid(t *testing.T) {
 	// assert.Equal(t, "user123", userID)
 }
 func TestGenerateRefreshToken_valid(t *testing.T) {
-	token, err := utils.GenerateRefreshToken("user456", "refresh@gmail.com")
+	token, err := utils.GenerateRefreshToken("user456", "refresh@gmail.com", "user", "session456")
 	assert.NoError(t, err, "GenerateRefreshToken failed")
 	assert.NotEmpty(t, token, "GenerateRefreshToken returned empty token")
 }
@@ -26,19 +28,64 @@ func TestGenerateRefreshToken_valid(t *testing.T) {
 func TestVerifyAndParseJWTToken_ValidToken(t *testing.T) {
 	userID := "user789"
 	email := "valid@gmail.com"
-	token, err := utils.GenerateAccessToken(userID, email)
+	role := "admin"
+	sessionID := "session789"
+	token, err := utils.GenerateAccessToken(userID, email, role, sessionID, "profile:read users:read")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
-	claims, err := utils.VerifyAndParseJWTToken(token)
+	claims, err := utils.VerifyAndParseJWTToken(token, utils.AccessTokenType)
 	assert.NoError(t, err)
 	assert.Equal(t, userID, claims.UserID)
 	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, role, claims.Role)
+	assert.Equal(t, sessionID, claims.SessionID)
+	assert.Equal(t, []string{"profile:read", "users:read"}, claims.Scopes())
+	assert.Equal(t, utils.AccessTokenType, claims.TokenType)
+	assert.NotEmpty(t, claims.ID, "token should carry a jti")
+	assert.Contains(t, claims.Audience, "backend-go")
+}
+
+func TestVerifyAndParseJWTToken_UniqueJTI(t *testing.T) {
+	first, err := utils.GenerateRefreshToken("user789", "valid@gmail.com", "user", "session789")
+	assert.NoError(t, err)
+	second, err := utils.GenerateRefreshToken("user789", "valid@gmail.com", "user", "session789")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second, "tokens issued in the same second must differ")
+}
+
+func TestVerifyAndParseJWTToken_WrongTokenType(t *testing.T) {
+	refreshToken, err := utils.GenerateRefreshToken("user789", "valid@gmail.com", "user", "session789")
+	assert.NoError(t, err)
+
+	claims, err := utils.VerifyAndParseJWTToken(refreshToken, utils.AccessTokenType)
+	assert.Error(t, err, "refresh token must not be accepted as access token")
+	assert.Nil(t, claims)
+
+	accessToken, err := utils.GenerateAccessToken("user789", "valid@gmail.com", "user", "session789", "profile:read")
+	assert.NoError(t, err)
+
+	claims, err = utils.VerifyAndParseJWTToken(accessToken, utils.RefreshTokenType)
+	assert.Error(t, err, "access token must not be accepted as refresh token")
+	assert.Nil(t, claims)
+}
+
+func TestVerifyAndParseJWTToken_MFATokenIsNoAccessToken(t *testing.T) {
+	mfaToken, err := utils.GenerateMFAToken("user789", "valid@gmail.com", "profile:read")
+	assert.NoError(t, err)
+
+	_, err = utils.VerifyAndParseJWTToken(mfaToken, utils.AccessTokenType)
+	assert.Error(t, err, "a login waiting for its second factor must not grant access")
+
+	claims, err := utils.VerifyAndParseJWTToken(mfaToken, utils.MFATokenType)
+	assert.NoError(t, err)
+	assert.Empty(t, claims.SessionID)
+	assert.Equal(t, "profile:read", claims.Scope, "the requested scope is carried to the second step")
 }
 
 func TestVerifyAndParseJWTToken_InvalidToken(t *testing.T) {
 	invalidToken := "invalid.token.string"
-	claims, err := utils.VerifyAndParseJWTToken(invalidToken)
+	claims, err := utils.VerifyAndParseJWTToken(invalidToken, utils.AccessTokenType)
 	assert.Error(t, err)
 	assert.Nil(t, claims)
 }
@@ -65,3 +112,61 @@ func TestExtractTokenFromHeader_InvalidFormat(t *testing.T) {
 	assert.Error(t, err)
 	assert.Empty(t, token)
 }
+
+func TestGenerateServiceToken(t *testing.T) {
+	token, err := utils.GenerateServiceToken("billing-service", "users:read profile:read")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.ServiceTokenType, utils.UnverifiedTokenType(token))
+
+	claims, err := utils.VerifyAndParseJWTToken(token, utils.ServiceTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "billing-service", claims.ClientID)
+	assert.Equal(t, "billing-service", claims.Subject)
+	assert.Empty(t, claims.UserID)
+	assert.Empty(t, claims.SessionID)
+	assert.Equal(t, []string{"users:read", "profile:read"}, claims.Scopes())
+
+	_, err = utils.VerifyAndParseJWTToken(token, utils.AccessTokenType)
+	assert.Error(t, err, "a service token must not be accepted as a user access token")
+}
+
+func TestUnverifiedTokenType(t *testing.T) {
+	accessToken, err := utils.GenerateAccessToken("user789", "valid@gmail.com", "user", "session789", "profile:read")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.AccessTokenType, utils.UnverifiedTokenType(accessToken))
+	assert.Equal(t, utils.TokenType(""), utils.UnverifiedTokenType("not-a-jwt"))
+}
+
+func TestVerifyJWTTokenIgnoringExpiry(t *testing.T) {
+	accessToken, err := utils.GenerateAccessToken("user789", "valid@gmail.com", "user", "session789", "profile:read")
+	assert.NoError(t, err)
+	claims, err := utils.VerifyJWTTokenIgnoringExpiry(accessToken, utils.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "user789", claims.UserID)
+
+	refreshToken, err := utils.GenerateRefreshToken("user789", "valid@gmail.com", "user", "session789")
+	assert.NoError(t, err)
+	_, err = utils.VerifyJWTTokenIgnoringExpiry(refreshToken, utils.AccessTokenType)
+	assert.Error(t, err, "the type is still checked")
+
+	expired := jwt.NewWithClaims(jwt.SigningMethodHS256, &utils.Claims{
+		UserID:    "user789",
+		TokenType: utils.AccessTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "backend-go",
+			Audience:  jwt.ClaimStrings{"backend-go"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	})
+	expired.Header["kid"] = "hs256"
+	signed, err := expired.SignedString(utils.JWT_SECRET_KEY)
+	assert.NoError(t, err)
+	claims, err = utils.VerifyJWTTokenIgnoringExpiry(signed, utils.AccessTokenType)
+	assert.NoError(t, err, "an expired token still identifies its owner")
+	assert.Equal(t, "user789", claims.UserID)
+
+	forged, err := expired.SignedString([]byte("another secret"))
+	assert.NoError(t, err)
+	_, err = utils.VerifyJWTTokenIgnoringExpiry(forged, utils.AccessTokenType)
+	assert.Error(t, err, "the signature is still checked")
+}
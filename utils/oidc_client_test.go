@@ -0,0 +1,173 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP is a minimal OpenID provider issuing ID tokens for a single authorization code
+type fakeIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	code     string
+	claims   jwt.MapClaims
+	verifier string // code_verifier received at the token endpoint
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &fakeIdP{key: key, code: "fake-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.JWKSet{Keys: []utils.JWK{{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: "idp-key",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != idp.code {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		idp.verifier = r.PostFormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, idp.claims)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *fakeIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	signed, err := token.SignedString(idp.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func (idp *fakeIdP) provider() *utils.OIDCProvider {
+	return utils.NewOIDCProvider(utils.OIDCProviderConfig{
+		Name:         "fake",
+		Issuer:       idp.server.URL,
+		ClientID:     "backend-go",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/user/oidc/fake/callback",
+	}, idp.server.Client())
+}
+
+func (idp *fakeIdP) idTokenClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "upstream-user",
+		"aud":            "backend-go",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"email":          "test@gmail.com",
+		"email_verified": true,
+		"nonce":          nonce,
+	}
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	authURL, err := provider.AuthCodeURL(ctx, "state123", "nonce123", utils.PKCEChallenge(verifier))
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, idp.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "state123", parsed.Query().Get("state"))
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+
+	idp.claims = idp.idTokenClaims("nonce123")
+	rawIDToken, err := provider.Exchange(ctx, idp.code, verifier)
+	require.NoError(t, err)
+	assert.Equal(t, verifier, idp.verifier)
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce123")
+	require.NoError(t, err)
+	assert.Equal(t, "test@gmail.com", claims.Email)
+	assert.True(t, bool(claims.EmailVerified))
+	assert.Equal(t, "upstream-user", claims.Subject)
+}
+
+func TestOIDCProvider_RejectsInvalidIDTokens(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	_, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.idTokenClaims("nonce123")), "other-nonce")
+	assert.Error(t, err, "nonce of another login")
+
+	wrongAudience := idp.idTokenClaims("nonce123")
+	wrongAudience["aud"] = "another-client"
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, wrongAudience), "nonce123")
+	assert.Error(t, err)
+
+	expired := idp.idTokenClaims("nonce123")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, expired), "nonce123")
+	assert.Error(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.idTokenClaims("nonce123"))
+	forged.Header["kid"] = "idp-key"
+	forgedToken, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, forgedToken, "nonce123")
+	assert.Error(t, err, "token not signed by the provider's key")
+}
+
+func TestOIDCProvider_EmailVerifiedAsString(t *testing.T) {
+	idp := newFakeIdP(t)
+	claims := idp.idTokenClaims("nonce123")
+	claims["email_verified"] = "true"
+
+	verified, err := idp.provider().VerifyIDToken(context.Background(), idp.sign(t, claims), "nonce123")
+	require.NoError(t, err)
+	assert.True(t, bool(verified.EmailVerified))
+}
+
+func TestOIDCProvider_IssuerMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := utils.NewOIDCProvider(utils.OIDCProviderConfig{
+		Name:     "fake",
+		Issuer:   idp.server.URL + "/",
+		ClientID: "backend-go",
+	}, idp.server.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.Error(t, err, "discovered issuer must match the configured one exactly")
+}
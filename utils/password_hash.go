@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2idParams are the tunable costs of an Argon2id hash, Memory is in KiB
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idParams checks configured costs before they are narrowed to the types of Argon2idParams,
+// argon2 panics on zero iterations or parallelism and a parallelism above 255 would wrap around
+func NewArgon2idParams(memoryKiB int, iterations int, parallelism int, saltLength int, keyLength int) (Argon2idParams, error) {
+	switch {
+	case parallelism < 1 || parallelism > math.MaxUint8:
+		return Argon2idParams{}, fmt.Errorf("argon2 parallelism must be 1-%d, got %d", math.MaxUint8, parallelism)
+	case iterations < 1 || int64(iterations) > math.MaxUint32:
+		return Argon2idParams{}, fmt.Errorf("argon2 iterations must be at least 1, got %d", iterations)
+	case memoryKiB < 8*parallelism || int64(memoryKiB) > math.MaxUint32:
+		return Argon2idParams{}, fmt.Errorf("argon2 memory must be at least 8 KiB per lane (%d KiB), got %d", 8*parallelism, memoryKiB)
+	case saltLength < 8 || keyLength < 16:
+		return Argon2idParams{}, fmt.Errorf("argon2 salt must be at least 8 bytes and the key at least 16")
+	}
+
+	return Argon2idParams{
+		Memory:      uint32(memoryKiB),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+		SaltLength:  uint32(saltLength),
+		KeyLength:   uint32(keyLength),
+	}, nil
+}
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// HashPasswordArgon2id returns the hash in the PHC string format, $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>,
+// so the parameters stay readable when they are tuned later
+func HashPasswordArgon2id(password string, params Argon2idParams) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPasswordHash checks the password against an Argon2id or a legacy bcrypt hash.
+// The parameters of an Argon2id hash are returned so the caller can tell if they are outdated, they are nil for bcrypt.
+func VerifyPasswordHash(password string, encoded string) (bool, *Argon2idParams, error) {
+	if isBcryptHash(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil, err
+		}
+		return err == nil, nil, nil
+	}
+
+	params, salt, key, err := decodeArgon2idHash(encoded)
+	if err != nil {
+		return false, nil, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(computed, key) == 1, params, nil
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func decodeArgon2idHash(encoded string) (*Argon2idParams, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+
+	params := &Argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 parameters: %s", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, fmt.Errorf("invalid argon2 key")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
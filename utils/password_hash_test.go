@@ -0,0 +1,89 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// small costs keep the tests fast
+var testArgon2idParams = utils.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashPasswordArgon2id_Verify(t *testing.T) {
+	hash, err := utils.HashPasswordArgon2id("correct horse", testArgon2idParams)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, params, err := utils.VerifyPasswordHash("correct horse", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NotNil(t, params)
+	assert.Equal(t, testArgon2idParams, *params)
+
+	ok, _, err = utils.VerifyPasswordHash("wrong horse", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestHashPasswordArgon2id_SaltsEveryHash(t *testing.T) {
+	first, err := utils.HashPasswordArgon2id("correct horse", testArgon2idParams)
+	require.NoError(t, err)
+	second, err := utils.HashPasswordArgon2id("correct horse", testArgon2idParams)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestVerifyPasswordHash_LegacyBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, params, err := utils.VerifyPasswordHash("correct horse", string(hash))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, params, "bcrypt hashes have no argon2 parameters")
+
+	ok, _, err = utils.VerifyPasswordHash("wrong horse", string(hash))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestVerifyPasswordHash_RejectsMalformedHashes(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+	} {
+		ok, _, err := utils.VerifyPasswordHash("password", encoded)
+		assert.Error(t, err, encoded)
+		assert.False(t, ok)
+	}
+}
+
+func TestNewArgon2idParams(t *testing.T) {
+	params, err := utils.NewArgon2idParams(64*1024, 3, 4, 16, 32)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Argon2idParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}, params)
+
+	invalid := []struct {
+		name                            string
+		memory, iterations, parallelism int
+	}{
+		{"no iterations", 64 * 1024, 0, 4},
+		{"no parallelism", 64 * 1024, 3, 0},
+		{"parallelism wraps around uint8", 64 * 1024, 3, 256},
+		{"negative memory", -1, 3, 4},
+		{"memory below 8 KiB per lane", 16, 3, 4},
+	}
+	for _, tt := range invalid {
+		_, err := utils.NewArgon2idParams(tt.memory, tt.iterations, tt.parallelism, 16, 32)
+		assert.Error(t, err, tt.name)
+	}
+}
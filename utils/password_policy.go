@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordRules are the configurable requirements for new passwords
+type PasswordRules struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	DisallowEmail bool
+}
+
+// PasswordViolation is one rule a password breaks, Code is stable for clients and Message is for humans
+type PasswordViolation struct {
+	Code    string
+	Message string
+}
+
+// the local part of the email is only matched from this length on, "jo" in a password is no leak
+const minEmailPartLength = 3
+
+// Validate returns every rule the password breaks, nil if it follows all of them
+func (rules PasswordRules) Validate(password string, email string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < rules.MinLength {
+		violations = append(violations, PasswordViolation{"too_short", fmt.Sprintf("must be at least %d characters long", rules.MinLength)})
+	}
+	if rules.MaxLength > 0 && length > rules.MaxLength {
+		violations = append(violations, PasswordViolation{"too_long", fmt.Sprintf("must be at most %d characters long", rules.MaxLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if rules.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{"missing_upper", "must contain an uppercase letter"})
+	}
+	if rules.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{"missing_lower", "must contain a lowercase letter"})
+	}
+	if rules.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{"missing_digit", "must contain a digit"})
+	}
+	if rules.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{"missing_symbol", "must contain a symbol"})
+	}
+
+	if rules.DisallowEmail && containsEmail(password, email) {
+		violations = append(violations, PasswordViolation{"contains_email", "must not contain your email address"})
+	}
+
+	return violations
+}
+
+func containsEmail(password string, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	if strings.Contains(password, email) {
+		return true
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	return utf8.RuneCountInString(localPart) >= minEmailPartLength && strings.Contains(password, localPart)
+}
@@ -0,0 +1,89 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPasswordRules = utils.PasswordRules{
+	MinLength:     10,
+	MaxLength:     128,
+	RequireUpper:  true,
+	RequireLower:  true,
+	RequireDigit:  true,
+	DisallowEmail: true,
+}
+
+func violationCodes(violations []utils.PasswordViolation) []string {
+	codes := []string{}
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestPasswordRules_Validate(t *testing.T) {
+	assert.Empty(t, testPasswordRules.Validate("Correct-Horse-42", "test@gmail.com"))
+
+	assert.Equal(t, []string{"too_short"}, violationCodes(testPasswordRules.Validate("Short1a", "test@gmail.com")))
+	assert.Equal(t, []string{"missing_upper", "missing_digit"}, violationCodes(testPasswordRules.Validate("all lowercase words", "test@gmail.com")))
+
+	symbols := testPasswordRules
+	symbols.RequireSymbol = true
+	assert.Equal(t, []string{"missing_symbol"}, violationCodes(symbols.Validate("CorrectHorse42", "test@gmail.com")))
+}
+
+func TestPasswordRules_CountsCharactersNotBytes(t *testing.T) {
+	rules := utils.PasswordRules{MinLength: 10}
+
+	assert.Equal(t, []string{"too_short"}, violationCodes(rules.Validate("äöüäöüäöü", "")), "9 characters in 18 bytes")
+}
+
+func TestPasswordRules_DisallowEmail(t *testing.T) {
+	assert.Equal(t, []string{"contains_email"}, violationCodes(testPasswordRules.Validate("Alice.Smith@Example.com1", "alice.smith@example.com")))
+	assert.Equal(t, []string{"contains_email"}, violationCodes(testPasswordRules.Validate("MyNameIsAlice.Smith1", "alice.smith@example.com")))
+	assert.Empty(t, testPasswordRules.Validate("Jo-Horse-Battery-42", "jo@example.com"), "too short a local part is not matched")
+}
+
+func writeRangeFile(t *testing.T, dir string, name string, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+}
+
+func TestBreachedPasswords_IsBreached(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	writeRangeFile(t, dir, "5BAA6.txt", "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n")
+
+	breached := &utils.BreachedPasswords{Dir: dir}
+	ok, err := breached.IsBreached("password")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = breached.IsBreached("Correct-Horse-42")
+	require.NoError(t, err)
+	assert.False(t, ok, "no range file for the prefix")
+}
+
+func TestBreachedPasswords_MinCount(t *testing.T) {
+	dir := t.TempDir()
+	writeRangeFile(t, dir, "5BAA6", "1e4c9b93f3f0682250b6cf8331b7ee68fd8:2\n")
+
+	ok, err := (&utils.BreachedPasswords{Dir: dir, MinCount: 1}).IsBreached("password")
+	require.NoError(t, err)
+	assert.True(t, ok, "file without extension and lowercase hashes")
+
+	ok, err = (&utils.BreachedPasswords{Dir: dir, MinCount: 3}).IsBreached("password")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBreachedPasswords_DisabledWithoutDataset(t *testing.T) {
+	ok, err := (&utils.BreachedPasswords{}).IsBreached("password")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
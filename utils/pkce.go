@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// VerifyPKCE checks a code_verifier against the S256 code_challenge of the authorization request (RFC 7636)
+func VerifyPKCE(codeVerifier string, codeChallenge string) bool {
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+
+	return SecureCompare(PKCEChallenge(codeVerifier), codeChallenge)
+}
+
+// PKCEChallenge derives the S256 code_challenge sent along with an authorization request
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,18 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.True(t, utils.VerifyPKCE(verifier, challenge))
+	assert.False(t, utils.VerifyPKCE(verifier+"x", challenge))
+	assert.False(t, utils.VerifyPKCE("short", challenge), "verifier shorter than 43 characters is invalid")
+}
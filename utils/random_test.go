@@ -0,0 +1,18 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSecureToken(t *testing.T) {
+	first, err := utils.GenerateSecureToken(16)
+	assert.NoError(t, err)
+	assert.Len(t, first, 22, "16 bytes should encode to 22 base64url characters")
+
+	second, err := utils.GenerateSecureToken(16)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second, "tokens should be unique")
+}
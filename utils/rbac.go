@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"backend-go/config"
+	"backend-go/constants"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// RBACPolicy maps every role to the permissions it grants. The permission "*" grants everything.
+type RBACPolicy struct {
+	DefaultRole string              `json:"default_role"` // role of new users and of accounts stored without a role
+	Roles       map[string][]string `json:"roles"`
+}
+
+var (
+	rbacPolicyMu sync.RWMutex
+	rbacPolicy   = DefaultRBACPolicy()
+)
+
+// DefaultRBACPolicy is used unless RBAC_POLICY_FILE points to another policy
+func DefaultRBACPolicy() *RBACPolicy {
+	return &RBACPolicy{
+		DefaultRole: constants.ROLE_USER,
+		Roles: map[string][]string{
+			constants.ROLE_ADMIN: {"*"},
+			constants.ROLE_USER: {
+				constants.PERMISSION_PROFILE_READ,
+				constants.PERMISSION_PROFILE_WRITE,
+				constants.PERMISSION_ACCOUNT_MANAGE,
+			},
+		},
+	}
+}
+
+// InitRBACPolicy loads the policy configured in the environment
+func InitRBACPolicy() error {
+	path := config.GetEnv("RBAC_POLICY_FILE", "")
+	if path == "" {
+		return nil
+	}
+
+	policy, err := LoadRBACPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	SetRBACPolicy(policy)
+	return nil
+}
+
+// LoadRBACPolicyFile reads a policy like {"default_role": "user", "roles": {"admin": ["*"], "user": ["profile:read"]}}
+func LoadRBACPolicyFile(path string) (*RBACPolicy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rbac policy: %w", err)
+	}
+
+	var policy RBACPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, fmt.Errorf("parsing rbac policy: %w", err)
+	}
+	if _, ok := policy.Roles[policy.DefaultRole]; !ok {
+		return nil, fmt.Errorf("rbac policy: default role %q is not defined", policy.DefaultRole)
+	}
+
+	return &policy, nil
+}
+
+func SetRBACPolicy(policy *RBACPolicy) {
+	rbacPolicyMu.Lock()
+	defer rbacPolicyMu.Unlock()
+	rbacPolicy = policy
+}
+
+func CurrentRBACPolicy() *RBACPolicy {
+	rbacPolicyMu.RLock()
+	defer rbacPolicyMu.RUnlock()
+	return rbacPolicy
+}
+
+// DefaultRole is the role new accounts get
+func DefaultRole() string {
+	return CurrentRBACPolicy().DefaultRole
+}
+
+// IsKnownRole reports whether the policy defines the role
+func IsKnownRole(role string) bool {
+	_, ok := CurrentRBACPolicy().Roles[role]
+	return ok
+}
+
+// HasPermission reports whether the role grants the permission, an empty role is treated as the default role
+func (p *RBACPolicy) HasPermission(role string, permission string) bool {
+	if role == "" {
+		role = p.DefaultRole
+	}
+
+	granted, ok := p.Roles[role]
+	if !ok {
+		return false
+	}
+	return slices.Contains(granted, "*") || slices.Contains(granted, permission)
+}
+
+func HasPermission(role string, permission string) bool {
+	return CurrentRBACPolicy().HasPermission(role, permission)
+}
+
+// Permissions returns what the role grants, an empty role is treated as the default role
+func (p *RBACPolicy) Permissions(role string) []string {
+	if role == "" {
+		role = p.DefaultRole
+	}
+	return slices.Clone(p.Roles[role])
+}
+
+// CapScopes keeps the requested scopes the role grants, in the order requested and without duplicates
+func (p *RBACPolicy) CapScopes(role string, requested []string) []string {
+	granted := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if p.HasPermission(role, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// GrantScopes decides the scope of a login: the requested scopes capped by the role,
+// or everything the role grants when the client did not ask for any
+func GrantScopes(role string, requested []string) []string {
+	policy := CurrentRBACPolicy()
+	if len(requested) == 0 {
+		return policy.Permissions(role)
+	}
+	return policy.CapScopes(role, requested)
+}
+
+// HasScope reports whether the scopes include the scope, "*" includes every scope
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, "*") || slices.Contains(scopes, scope)
+}
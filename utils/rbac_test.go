@@ -0,0 +1,71 @@
+package utils_test
+
+import (
+	"backend-go/constants"
+	"backend-go/utils"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRBACPolicy(t *testing.T) {
+	policy := utils.DefaultRBACPolicy()
+
+	assert.True(t, policy.HasPermission(constants.ROLE_ADMIN, constants.PERMISSION_USERS_DELETE), "admin holds every permission")
+	assert.True(t, policy.HasPermission(constants.ROLE_USER, constants.PERMISSION_PROFILE_READ))
+	assert.False(t, policy.HasPermission(constants.ROLE_USER, constants.PERMISSION_USERS_READ))
+	assert.True(t, policy.HasPermission("", constants.PERMISSION_PROFILE_READ), "accounts without a role get the default role")
+	assert.False(t, policy.HasPermission("superuser", constants.PERMISSION_PROFILE_READ), "unknown roles grant nothing")
+}
+
+func TestLoadRBACPolicyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rbac.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default_role": "member",
+		"roles": {"member": ["profile:read"], "support": ["profile:read", "users:read"]}
+	}`), 0o600))
+
+	policy, err := utils.LoadRBACPolicyFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "member", policy.DefaultRole)
+	assert.True(t, policy.HasPermission("support", "users:read"))
+	assert.False(t, policy.HasPermission("member", "users:read"))
+}
+
+func TestLoadRBACPolicyFile_UndefinedDefaultRole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"default_role": "guest", "roles": {"admin": ["*"]}}`), 0o600))
+
+	_, err := utils.LoadRBACPolicyFile(path)
+	assert.Error(t, err)
+}
+
+func TestRBACPolicy_CapScopes(t *testing.T) {
+	policy := utils.DefaultRBACPolicy()
+
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ},
+		policy.CapScopes(constants.ROLE_USER, []string{constants.PERMISSION_PROFILE_READ, constants.PERMISSION_USERS_READ, constants.PERMISSION_PROFILE_READ}),
+		"scopes the role does not grant are dropped")
+	assert.Equal(t, []string{constants.PERMISSION_USERS_READ}, policy.CapScopes(constants.ROLE_ADMIN, []string{constants.PERMISSION_USERS_READ}))
+	assert.Empty(t, policy.CapScopes("superuser", []string{constants.PERMISSION_PROFILE_READ}))
+}
+
+func TestGrantScopes(t *testing.T) {
+	utils.SetRBACPolicy(utils.DefaultRBACPolicy())
+
+	assert.ElementsMatch(t, []string{constants.PERMISSION_PROFILE_READ, constants.PERMISSION_PROFILE_WRITE, constants.PERMISSION_ACCOUNT_MANAGE}, utils.GrantScopes(constants.ROLE_USER, nil),
+		"without a request the token gets everything the role grants")
+	assert.Equal(t, []string{constants.PERMISSION_PROFILE_READ}, utils.GrantScopes(constants.ROLE_USER, []string{constants.PERMISSION_PROFILE_READ}))
+	assert.Equal(t, []string{"*"}, utils.GrantScopes(constants.ROLE_ADMIN, nil))
+}
+
+func TestHasScope(t *testing.T) {
+	assert.True(t, utils.HasScope([]string{"profile:read"}, "profile:read"))
+	assert.False(t, utils.HasScope([]string{"profile:read"}, "profile:write"))
+	assert.True(t, utils.HasScope([]string{"*"}, "users:delete"))
+	assert.False(t, utils.HasScope(nil, "profile:read"))
+}
@@ -0,0 +1,8 @@
+package utils
+
+import "log"
+
+// LogSecurityEvent writes a security relevant event with a fixed prefix so it can be picked up by log alerts
+func LogSecurityEvent(event string, format string, args ...interface{}) {
+	log.Printf("[SECURITY] "+event+": "+format, args...)
+}
@@ -0,0 +1,67 @@
+package utils_test
+
+import (
+	"backend-go/utils"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ascii "12345678901234567890", the SHA1 secret of the RFC 6238 test vectors
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode_RFC6238Vectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := utils.GenerateTOTPCode(rfcTOTPSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := utils.GenerateTOTPCode(secret, now)
+	require.NoError(t, err)
+	step, ok := utils.ValidateTOTPCode(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/utils.TOTPPeriod, step)
+
+	previous, err := utils.GenerateTOTPCode(secret, now.Add(-utils.TOTPPeriod*time.Second))
+	require.NoError(t, err)
+	_, ok = utils.ValidateTOTPCode(secret, previous, now)
+	assert.True(t, ok, "clock drift of one step is tolerated")
+
+	stale, err := utils.GenerateTOTPCode(secret, now.Add(-5*utils.TOTPPeriod*time.Second))
+	require.NoError(t, err)
+	_, ok = utils.ValidateTOTPCode(secret, stale, now)
+	assert.False(t, ok)
+
+	_, ok = utils.ValidateTOTPCode(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := utils.TOTPProvisioningURI("backend-go", "test@gmail.com", rfcTOTPSecret)
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/backend-go:test@gmail.com", parsed.Path)
+	assert.Equal(t, rfcTOTPSecret, parsed.Query().Get("secret"))
+	assert.Equal(t, "backend-go", parsed.Query().Get("issuer"))
+}